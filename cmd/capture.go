@@ -0,0 +1,517 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/kpture/kpture/pkg/display"
+	"github.com/kpture/kpture/pkg/filter"
+	"github.com/kpture/kpture/pkg/kubernetes"
+	"github.com/kpture/kpture/pkg/merge"
+	"github.com/kpture/kpture/pkg/pcapfile"
+	"github.com/kpture/kpture/pkg/session"
+	"github.com/kpture/kpture/pkg/socket"
+	v1 "k8s.io/api/core/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+//podCapture is a pod capture started during the session
+type podCapture struct {
+	pod    v1.Pod
+	intf   string
+	stream *socket.Stream
+}
+
+//captureSession keep track of the running captures of the root command
+type captureSession struct {
+	ctx           context.Context
+	client        *k8s.Clientset
+	dial          string
+	folder        string
+	merged        *pcapfile.File
+	live          []*pcapfile.File
+	merger        *merge.Writer
+	workloads     []*kubernetes.Workload
+	workloadFiles map[*kubernetes.Workload]*pcapfile.File
+	fileOptions   pcapfile.Options
+	bpfFilter     string
+	writeFilter   *filter.Filter
+	displayFilter *filter.Filter
+	printer       *display.Printer
+	events        *display.Printer
+	filter        func(pod v1.Pod) bool
+	recorder      *session.Recorder
+	limits        socket.Limits
+	counter       *socket.Counter
+	reconnect     socket.Backoff
+	interfaces    []string
+	tls           *tls.Config
+	token         string
+	logs          *kubernetes.LogCollector
+	clusterEvents *session.ClusterRecorder
+	markers       []socket.PacketWriter
+	//streamer write the live streams as the packets arrive, they aren't held for the merge window
+	streamer *merge.Writer
+
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	starting sync.WaitGroup
+	closed   bool
+	//streams are the captures of each pod, nil while they start
+	streams  map[string][]*socket.Stream
+	podFiles map[string]*pcapfile.File
+	captures []podCapture
+	files    []*pcapfile.File
+}
+
+//createPcap create a pcap file closed at the end of the session
+func (s *captureSession) createPcap(path string, opts pcapfile.Options) (*pcapfile.File, error) {
+	f, err := pcapfile.Create(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.files = append(s.files, f)
+	return f, nil
+}
+
+//removePcap delete a file created with createPcap, s.mu being held
+func (s *captureSession) removePcap(f *pcapfile.File) {
+	for i, file := range s.files {
+		if file == f {
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			break
+		}
+	}
+	if err := f.Remove(); err != nil {
+		fmt.Println(err)
+	}
+}
+
+//addLive stream the merged capture to a viewer, such as stdout or a named pipe
+func (s *captureSession) addLive(w io.WriteCloser) error {
+	f, err := pcapfile.NewStream(w, s.fileOptions)
+	if err != nil {
+		return err
+	}
+	s.live = append(s.live, f)
+	s.files = append(s.files, f)
+	return nil
+}
+
+//owners return the workloads of the session the pod belongs to
+func (s *captureSession) owners(pod v1.Pod) []*kubernetes.Workload {
+	owners := []*kubernetes.Workload{}
+	for _, w := range s.workloads {
+		owned, err := w.Owns(s.client, pod)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if owned {
+			owners = append(owners, w)
+		}
+	}
+	return owners
+}
+
+//match return true when the pod is a target of the session
+func (s *captureSession) match(pod v1.Pod) bool {
+	if s.filter != nil && s.filter(pod) {
+		return true
+	}
+	return len(s.owners(pod)) > 0
+}
+
+//start capture the selected interfaces of the pod unless it is already captured
+func (s *captureSession) start(pod v1.Pod, workloads []*kubernetes.Workload) {
+	s.mu.Lock()
+	if _, ok := s.streams[podKey(pod)]; ok || s.closed {
+		s.mu.Unlock()
+		return
+	}
+	// The pod is reserved while its captures start, without holding the lock while the proxy is dialed.
+	// The shutdown waits for the starting pods
+	s.streams[podKey(pod)] = nil
+	s.starting.Add(1)
+	s.mu.Unlock()
+	defer s.starting.Done()
+
+	streams := []*socket.Stream{}
+	var shared *pcapfile.File
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(streams) == 0 {
+			delete(s.streams, podKey(pod))
+			if shared != nil {
+				// No interface of the pod was captured, its file would stay empty
+				delete(s.podFiles, podKey(pod))
+				s.removePcap(shared)
+			}
+			return
+		}
+		s.streams[podKey(pod)] = streams
+		s.logs.Collect(pod)
+	}()
+
+	interfaces, err := kubernetes.SelectInterfaces(pod, s.interfaces)
+	if err != nil {
+		fmt.Println(podKey(pod), err)
+		return
+	}
+	dir := filepath.Join(s.folder, pod.Namespace, pod.Name)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// A pcapng file holds every interface of the pod, pcap files a single one
+	if len(interfaces) > 1 && s.fileOptions.Format == pcapfile.FormatPcapng {
+		s.mu.Lock()
+		shared, err = s.createPcap(nextFileName(dir, pod.Name, s.fileOptions.Extension()), s.podOptions(pod))
+		if err == nil {
+			s.podFiles[podKey(pod)] = shared
+		}
+		s.mu.Unlock()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	for _, intf := range interfaces {
+		filename := ""
+		switch {
+		case shared != nil:
+			filename = shared.Path()
+		case len(interfaces) > 1:
+			filename = nextFileName(dir, pod.Name+"-"+intf.Interface, s.fileOptions.Extension())
+		default:
+			filename = nextFileName(dir, pod.Name, s.fileOptions.Extension())
+		}
+		if stream := s.startInterface(pod, intf, len(interfaces) > 1, filename, shared, workloads); stream != nil {
+			streams = append(streams, stream)
+		}
+	}
+}
+
+//startInterface capture an interface of the pod, and return its stream unless the capture failed
+func (s *captureSession) startInterface(pod v1.Pod, intf kubernetes.NetworkInterface, multiple bool, filename string, shared *pcapfile.File, workloads []*kubernetes.Workload) *socket.Stream {
+	source := display.Source{Namespace: pod.Namespace, Pod: pod.Name, Node: pod.Spec.NodeName}
+	if multiple {
+		source.Interface = intf.Interface
+	}
+	event := session.Event{Namespace: pod.Namespace, Pod: pod.Name, Interface: intf.Interface, Node: pod.Spec.NodeName, IP: pod.Status.PodIP, File: filename}
+	capture := socket.Capture{ContainerName: pod.Name, ContainerNamespace: pod.Namespace, Interface: intf.Interface, FileName: filename, Filter: s.bpfFilter, Snaplen: s.fileOptions.Snaplen}
+	stream, err := socket.StartCapture(s.ctx, capture, s.dial, socket.Options{
+		File:          s.podOptions(pod),
+		Output:        shared,
+		Interface:     podInterface(pod, intf),
+		Outputs:       s.outputs(workloads),
+		Limits:        s.limits,
+		Counter:       s.counter,
+		WriteFilter:   s.writeFilter,
+		DisplayFilter: s.displayFilter,
+		Printer:       s.printer,
+		Events:        s.events,
+		Source:        source,
+		Reconnect:     s.reconnect,
+		TLS:           s.tls,
+		Token:         s.token,
+		OnGap: func(gap session.Gap) {
+			e := event
+			e.Type, e.Message, e.Gap = session.CaptureGap, gap.Reason, &gap
+			s.recorder.Record(e)
+		},
+	})
+	if err != nil {
+		fmt.Println(source, err)
+		event.Type, event.Message = session.CaptureFailed, err.Error()
+		s.recorder.Record(event)
+		return nil
+	}
+	event.Type = session.CaptureStarted
+	if response := stream.Response(); response != nil {
+		event.ContainerID = response.ContainerID
+	} else {
+		fmt.Println(source, "the proxy did not answer the handshake, it is taken as an older proxy and the packets as ethernet frames")
+	}
+	s.recorder.Record(event)
+	s.mu.Lock()
+	s.captures = append(s.captures, podCapture{pod: pod, intf: intf.Interface, stream: stream})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		<-stream.Done()
+		event.Type, event.Message = session.CaptureStopped, stream.Reason()
+		if err := stream.Err(); err != nil {
+			event.Type, event.Message = session.CaptureFailed, err.Error()
+		}
+		s.recorder.Record(event)
+	}()
+	return stream
+}
+
+//outputs return the writers of the files shared by the pod, once its capture reported the link type of its packets.
+//Each pod is an interface of the merged files
+func (s *captureSession) outputs(workloads []*kubernetes.Workload) func(intf pcapfile.Interface) []socket.PacketWriter {
+	return func(intf pcapfile.Interface) []socket.PacketWriter {
+		writers := []socket.PacketWriter{}
+		for _, f := range append(append([]*pcapfile.File{s.merged}, s.live...), s.filesOf(workloads)...) {
+			w, err := f.Interface(intf)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			// Shared files are written by a single goroutine
+			writers = append(writers, s.writerOf(f).Output(w))
+		}
+		return writers
+	}
+}
+
+//writerOf return the writer of a shared file, live streams being written without waiting for the merge window
+func (s *captureSession) writerOf(f *pcapfile.File) *merge.Writer {
+	for _, live := range s.live {
+		if f == live {
+			return s.streamer
+		}
+	}
+	return s.merger
+}
+
+//filesOf return the merged files of the workloads
+func (s *captureSession) filesOf(workloads []*kubernetes.Workload) []*pcapfile.File {
+	files := []*pcapfile.File{}
+	for _, w := range workloads {
+		files = append(files, s.workloadFiles[w])
+	}
+	return files
+}
+
+//idle return a channel closed once every capture started so far has ended
+func (s *captureSession) idle() <-chan struct{} {
+	idle := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(idle)
+	}()
+	return idle
+}
+
+//shutdown stop the running captures, close every file of the session and return its summary
+func (s *captureSession) shutdown(start time.Time, reason string) *session.Summary {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.starting.Wait()
+	s.mu.Lock()
+	captures := append([]podCapture{}, s.captures...)
+	s.mu.Unlock()
+
+	for _, c := range captures {
+		if err := c.stream.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	s.wg.Wait()
+	s.merger.Close()
+	s.streamer.Close()
+	s.logs.Close()
+
+	summary := &session.Summary{Start: start, End: time.Now(), StopReason: reason, MergedDropped: s.merger.Stats().Dropped + s.streamer.Stats().Dropped}
+	for _, c := range captures {
+		stats := c.stream.Stats()
+		pod := session.PodSummary{Namespace: c.pod.Namespace, Pod: c.pod.Name, Interface: c.intf, File: c.stream.Capture.FileName, Packets: stats.Packets, Bytes: stats.Bytes, StopReason: c.stream.Reason()}
+		if err := c.stream.Err(); err != nil {
+			pod.Error = err.Error()
+		}
+		pod.Gaps = len(c.stream.Gaps())
+		summary.Add(pod)
+	}
+
+	for _, f := range s.files {
+		if err := f.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if err := s.recorder.Close(); err != nil {
+		fmt.Println(err)
+	}
+	if s.clusterEvents != nil {
+		if err := s.clusterEvents.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return summary
+}
+
+//addMarkers write the cluster events to an interface of the merged pcapng files
+func (s *captureSession) addMarkers() error {
+	for _, f := range append([]*pcapfile.File{s.merged}, s.live...) {
+		w, err := f.Markers("kubernetes events")
+		if err != nil {
+			return err
+		}
+		s.markers = append(s.markers, s.writerOf(f).Output(w))
+	}
+	return nil
+}
+
+//OnClusterEvent record a kubernetes event or a status change of a captured pod or of its node
+func (s *captureSession) OnClusterEvent(e session.ClusterEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if err := s.clusterEvents.Record(e); err != nil {
+		fmt.Println(err)
+	}
+	marker := []byte(e.String())
+	for _, w := range s.markers {
+		w.WritePacket(gopacket.CaptureInfo{Timestamp: e.Time, CaptureLength: len(marker), Length: len(marker)}, marker)
+	}
+}
+
+//captured return true when a pod was captured during the session
+func (s *captureSession) captured(namespace, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.captures {
+		if c.pod.Namespace == namespace && c.pod.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//capturedNode return true when a pod of the node was captured during the session
+func (s *captureSession) capturedNode(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.captures {
+		if c.pod.Spec.NodeName == name {
+			return true
+		}
+	}
+	return false
+}
+
+//OnRunning start capturing the pods appearing while following
+func (s *captureSession) OnRunning(pod v1.Pod) {
+	s.mu.Lock()
+	_, ok := s.streams[podKey(pod)]
+	s.mu.Unlock()
+	if ok {
+		return
+	}
+
+	fmt.Println("Following new pod", podKey(pod))
+	s.recorder.Record(session.Event{Type: session.PodRunning, Namespace: pod.Namespace, Pod: pod.Name, Node: pod.Spec.NodeName, IP: pod.Status.PodIP})
+	s.start(pod, s.owners(pod))
+}
+
+//OnTerminating record the deletion request, the capture goes on until the pod is gone
+func (s *captureSession) OnTerminating(pod v1.Pod) {
+	s.recorder.Record(session.Event{Type: session.PodTerminating, Namespace: pod.Namespace, Pod: pod.Name, Node: pod.Spec.NodeName})
+}
+
+//OnStopped close the capture of a pod which is not running anymore
+func (s *captureSession) OnStopped(pod v1.Pod, reason string) {
+	s.recorder.Record(session.Event{Type: session.PodStopped, Namespace: pod.Namespace, Pod: pod.Name, Node: pod.Spec.NodeName, Message: reason})
+
+	s.mu.Lock()
+	streams := s.streams[podKey(pod)]
+	if streams == nil {
+		// Not captured, or still starting: the captures of a starting pod end along with its containers
+		s.mu.Unlock()
+		return
+	}
+	shared := s.podFiles[podKey(pod)]
+	delete(s.streams, podKey(pod))
+	delete(s.podFiles, podKey(pod))
+	s.mu.Unlock()
+
+	fmt.Println("Pod", podKey(pod), reason+", closing its capture")
+	for _, stream := range streams {
+		if err := stream.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if shared != nil {
+		if err := shared.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+//podInterface describe an interface of a pod in pcapng files, along with the pod metadata
+func podInterface(pod v1.Pod, intf kubernetes.NetworkInterface) pcapfile.Interface {
+	ips := intf.IPs
+	if len(ips) == 0 && intf.Default {
+		ips = podIPs(pod)
+	}
+	description := fmt.Sprintf("pod %s on node %s", podKey(pod), pod.Spec.NodeName)
+	if intf.Network != "" {
+		description += ", network " + intf.Network
+	}
+	return pcapfile.Interface{
+		Name:        podKey(pod) + "/" + intf.Interface,
+		Description: description,
+		Comment:     podMetadata(pod, ips),
+	}
+}
+
+//podOptions return the options of the files of a pod, whose section comment holds the pod metadata
+func (s *captureSession) podOptions(pod v1.Pod) pcapfile.Options {
+	opts := s.fileOptions
+	opts.Comment = fmt.Sprintf("%s, pod %s %s", opts.Comment, podKey(pod), podMetadata(pod, podIPs(pod)))
+	return opts
+}
+
+//podMetadata describe the node, the IPs and the labels of a pod
+func podMetadata(pod v1.Pod, ips []string) string {
+	labels := []string{}
+	for k, v := range pod.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	return fmt.Sprintf("node=%s ips=%s labels=%s", pod.Spec.NodeName, strings.Join(ips, ","), strings.Join(labels, ","))
+}
+
+func podIPs(pod v1.Pod) []string {
+	ips := []string{}
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	return ips
+}
+
+//podKey return the namespace/name identifier of a pod
+func podKey(pod v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
+
+//nextFileName return a file name of the folder which does not exist yet,
+//so a pod recreated with the same name does not overwrite its previous capture
+func nextFileName(dir string, name string, ext string) string {
+	filename := filepath.Join(dir, name+ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return filename
+		}
+		filename = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, ext))
+	}
+}
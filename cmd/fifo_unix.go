@@ -0,0 +1,48 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+//mkfifo create a named pipe at path, unless one already exists
+func mkfifo(path string) (bool, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		return false, nil
+	}
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//openFifo open the named pipe for writing, which blocks until a reader opens it or ctx is done
+func openFifo(ctx context.Context, path string) (*os.File, error) {
+	type result struct {
+		f   *os.File
+		err error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		opened <- result{f, err}
+	}()
+	select {
+	case r := <-opened:
+		return r.f, r.err
+	case <-ctx.Done():
+		// Opening the pipe for reading releases the pending open
+		if r, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0); err == nil {
+			r.Close()
+		}
+		if w := <-opened; w.f != nil {
+			w.f.Close()
+		}
+		return nil, fmt.Errorf("interrupted while waiting for a reader on %s", path)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenFifo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kpture.pipe")
+	if created, err := mkfifo(path); err != nil || !created {
+		t.Fatalf("mkfifo() = %v, %v", created, err)
+	}
+	if created, err := mkfifo(path); err != nil || created {
+		t.Fatalf("mkfifo() of an existing pipe = %v, %v", created, err)
+	}
+
+	// Without a reader, the open is interrupted along with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if f, err := openFifo(ctx, path); err == nil {
+		f.Close()
+		t.Fatal("openFifo() returned without a reader")
+	}
+
+	go func() {
+		if r, err := os.Open(path); err == nil {
+			r.Close()
+		}
+	}()
+	f, err := openFifo(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+//mkfifo is not supported on windows, where named pipes live in their own namespace
+func mkfifo(path string) (bool, error) {
+	return false, errors.New("--fifo is not supported on windows, use -w - instead")
+}
+
+//openFifo is not supported on windows, mkfifo already refused the pipe
+func openFifo(ctx context.Context, path string) (*os.File, error) {
+	return nil, errors.New("--fifo is not supported on windows")
+}
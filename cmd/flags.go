@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//byteSize is a flag value holding a size in bytes, accepting k, m, g and t suffixes
+type byteSize uint64
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier uint64
+}{
+	{"t", 1 << 40},
+	{"g", 1 << 30},
+	{"m", 1 << 20},
+	{"k", 1 << 10},
+	{"", 1},
+}
+
+func (b *byteSize) String() string {
+	v := uint64(*b)
+	for _, unit := range sizeUnits {
+		if v != 0 && v%unit.multiplier == 0 {
+			return strconv.FormatUint(v/unit.multiplier, 10) + strings.ToUpper(unit.suffix)
+		}
+	}
+	return "0"
+}
+
+func (b *byteSize) Set(s string) error {
+	value := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "b")
+	// Binary units such as Mi are read as M, an i must follow a unit
+	if trimmed := strings.TrimSuffix(value, "i"); trimmed != value {
+		if trimmed == "" || !strings.ContainsAny(trimmed[len(trimmed)-1:], "kmgt") {
+			return fmt.Errorf("invalid size %q", s)
+		}
+		value = trimmed
+	}
+	for _, unit := range sizeUnits {
+		if unit.suffix != "" && !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(value, unit.suffix), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size %q", s)
+		}
+		if n > math.MaxUint64/unit.multiplier {
+			return fmt.Errorf("size %q is too large", s)
+		}
+		*b = byteSize(n * unit.multiplier)
+		return nil
+	}
+	return fmt.Errorf("invalid size %q", s)
+}
+
+func (b *byteSize) Type() string {
+	return "size"
+}
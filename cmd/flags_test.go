@@ -0,0 +1,47 @@
+package cmd
+
+import "testing"
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		value     string
+		size      byteSize
+		valid     bool
+		formatted string
+	}{
+		{"0", 0, true, "0"},
+		{"1500", 1500, true, "1500"},
+		{"10k", 10 << 10, true, "10K"},
+		{"10KB", 10 << 10, true, "10K"},
+		{"100Mi", 100 << 20, true, "100M"},
+		{"2GiB", 2 << 30, true, "2G"},
+		{" 1g ", 1 << 30, true, "1G"},
+		{"2048k", 2 << 20, true, "2M"},
+		{"3T", 3 << 40, true, "3T"},
+		{"16777215t", 16777215 << 40, true, "16777215T"},
+		{"10b", 10, true, "10"},
+		{"1.5g", 0, false, ""},
+		{"10i", 0, false, ""},
+		{"ib", 0, false, ""},
+		{"kb", 0, false, ""},
+		{"16777216T", 0, false, ""},
+		{"20000000T", 0, false, ""},
+		{"18446744073709551616", 0, false, ""},
+		{"-1", 0, false, ""},
+		{"10p", 0, false, ""},
+		{"", 0, false, ""},
+	}
+	for _, tt := range tests {
+		var b byteSize
+		if err := b.Set(tt.value); (err == nil) != tt.valid {
+			t.Errorf("Set(%q) error = %v, want valid %v", tt.value, err, tt.valid)
+			continue
+		}
+		if !tt.valid {
+			continue
+		}
+		if b != tt.size || b.String() != tt.formatted {
+			t.Errorf("Set(%q) = %d (%s), want %d (%s)", tt.value, b, b.String(), tt.size, tt.formatted)
+		}
+	}
+}
@@ -37,11 +37,18 @@ import (
 	"github.com/spf13/cobra"
 )
 
+//InstallTLS create the TLS secret and let the proxy serve TLS with it
+var InstallTLS bool
+
+//ProxyImage is the image of the kpture proxy
+var ProxyImage string
+
 // installCmd represents the install command
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install kpture tools on your kubernetes cluster",
-	Long:  `This perform the installation of a daemonset, a proxy and a nodePort service`,
+	Long: `This perform the installation of a daemonset, a proxy and a nodePort service.
+With --tls, a TLS secret securing the captures is created as well, for a proxy image serving TLS given with --proxy-image`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		socketpath := ""
@@ -64,7 +71,10 @@ var installCmd = &cobra.Command{
 		cobra.CheckErr(err)
 		// install.InstallDaemonset(client, "kpture", "moby", "/run/containerd/containerd.sock")
 		install.InstallDaemonset(client, "kpture", ctrnamespace, socketpath)
-		install.InstallProxy(client, "kpture")
+		if InstallTLS {
+			install.InstallTLSSecret(client, "kpture")
+		}
+		install.InstallProxy(client, "kpture", ProxyImage, InstallTLS)
 		install.Installservice(client, "kpture")
 		install.InstallRole("kpture", config)
 		install.InstallRoleBinding("kpture", config)
@@ -73,6 +83,8 @@ var installCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(installCmd)
+	installCmd.Flags().BoolVar(&InstallTLS, "tls", false, "secure the captures with TLS, the proxy image must serve TLS")
+	installCmd.Flags().StringVar(&ProxyImage, "proxy-image", install.ProxyImage, "image of the kpture proxy")
 
 	// Here you will define your flags and configuration settings.
 
@@ -16,34 +16,108 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/kpture/kpture/pkg/display"
+	"github.com/kpture/kpture/pkg/filter"
+	"github.com/kpture/kpture/pkg/merge"
+	"github.com/kpture/kpture/pkg/session"
 	"github.com/spf13/cobra"
 )
 
+//LogPods select the pods of the timeline
+var LogPods []string
+
+//LogContainers select the containers whose log lines are shown
+var LogContainers []string
+
+//LogProtocol is the display filter or BPF expression selecting the packets of the timeline
+var LogProtocol string
+
+//LogSince is the start of the time window of the timeline
+var LogSince string
+
+//LogUntil is the end of the time window of the timeline
+var LogUntil string
+
+//LogFormat is the format of the timeline
+var LogFormat string
+
 // logCmd represents the log command
 var logCmd = &cobra.Command{
-	Use:   "log",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "log <session folder>",
+	Short: "Show the packets and the container logs of a session in timestamp order",
+	Long: `Interleave the container log lines saved with --logs and a one line summary of each packet
+captured on the pods of a session folder, in the order of their timestamps.
+
+The time window is given as RFC 3339 timestamps, or as durations from the start of the session:
+
+  kpture log out --pod nginx --protocol "http or dns" --since 30s --until 2m`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		folder := args[0]
+		printer, err := display.NewPrinter(os.Stdout, LogFormat)
+		cobra.CheckErr(err)
 
+		opts := merge.TimelineOptions{Pods: LogPods, Containers: LogContainers}
+		if LogProtocol != "" {
+			// BPF expressions are compiled again for the link type of each file
+			opts.Filter, err = filter.Parse(LogProtocol, layers.LinkTypeEthernet)
+			cobra.CheckErr(err)
+		}
+		opts.Since, err = timelineTime(folder, LogSince)
+		cobra.CheckErr(err)
+		opts.Until, err = timelineTime(folder, LogUntil)
+		cobra.CheckErr(err)
+
+		timeline, err := merge.OpenTimeline(folder, opts)
+		cobra.CheckErr(err)
+		defer timeline.Close()
+		for {
+			entry, err := timeline.Next()
+			if err == io.EOF {
+				return
+			}
+			cobra.CheckErr(err)
+			if entry.Packet != nil {
+				printer.Print(entry.Source, entry.Packet)
+			} else {
+				printer.PrintLog(entry.Source, entry.Container, entry.Time, entry.Line)
+			}
+		}
 	},
 }
 
+//timelineTime parse a bound of the time window, either a timestamp or a duration from the start of the session
+func timelineTime(folder string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected an RFC 3339 timestamp or a duration from the start of the session", value)
+	}
+	summary, err := session.ReadSummary(folder)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("durations are measured from the start of the session: %w", err)
+	}
+	return summary.Start.Add(d), nil
+}
+
 func init() {
 	rootCmd.AddCommand(logCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// logCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// logCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	logCmd.Flags().StringArrayVarP(&LogPods, "pod", "p", []string{}, "name or namespace/name of a pod to show (repeatable)")
+	logCmd.Flags().StringArrayVarP(&LogContainers, "container", "c", []string{}, "name of a container whose log lines are shown (repeatable)")
+	logCmd.Flags().StringVar(&LogProtocol, "protocol", "", "display filter or BPF expression selecting the packets shown (e.g. \"dns\" or \"tcp.port == 443\")")
+	logCmd.Flags().StringVar(&LogSince, "since", "", "show the entries from this time, an RFC 3339 timestamp or a duration from the start of the session")
+	logCmd.Flags().StringVar(&LogUntil, "until", "", "show the entries up to this time, an RFC 3339 timestamp or a duration from the start of the session")
+	logCmd.Flags().StringVar(&LogFormat, "output-format", display.FormatText, "format of the timeline: text, json or verbose")
 }
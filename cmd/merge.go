@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 Stephane Guillemot <kpture.git@gmail.com>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kpture/kpture/pkg/merge"
+	"github.com/kpture/kpture/pkg/pcapfile"
+	"github.com/spf13/cobra"
+)
+
+//MergeOutput is the file written by the merge command
+var MergeOutput string
+
+//MergeFormat is the format of the file written by the merge command
+var MergeFormat string
+
+// mergeCmd represents the merge command
+var mergeCmd = &cobra.Command{
+	Use:   "merge -w merged.pcap file.pcap ...",
+	Short: "Merge capture files in the order of their timestamps",
+	Long: `Merge pcap and pcapng files, such as the per pod captures of a session, into a single file
+where the packets of every file are sorted by timestamp.
+
+The merged file is written as pcapng, with an interface per file, when the files have different link types.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if MergeOutput == "" {
+			cobra.CheckErr(errors.New("an output file is required, see -w"))
+		}
+		packets, err := merge.Files(MergeOutput, args, pcapfile.Options{Format: MergeFormat})
+		cobra.CheckErr(err)
+		fmt.Printf("Merged %d packets from %d files into %s\n", packets, len(args), MergeOutput)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+
+	mergeCmd.Flags().StringVarP(&MergeOutput, "write", "w", "", "merged capture file")
+	mergeCmd.Flags().StringVar(&MergeFormat, "format", pcapfile.FormatPcap, "format of the merged file, pcap or pcapng (one interface per file)")
+}
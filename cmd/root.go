@@ -31,19 +31,31 @@ POSSIBILITY OF SUCH DAMAGE.
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcapgo"
+	"github.com/kpture/kpture/pkg/display"
+	"github.com/kpture/kpture/pkg/filter"
 	"github.com/kpture/kpture/pkg/kubernetes"
+	"github.com/kpture/kpture/pkg/merge"
+	"github.com/kpture/kpture/pkg/pcapfile"
+	"github.com/kpture/kpture/pkg/session"
 	"github.com/kpture/kpture/pkg/socket"
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -51,8 +63,11 @@ import (
 
 var cfgFile string
 
-//Namespace represent the kubernetes Namespace provided in configuration
-var Namespace string
+//Namespaces represent the kubernetes Namespaces provided in configuration
+var Namespaces []string
+
+//AllNamespaces capture the pods of every namespace
+var AllNamespaces bool
 
 //Kubeconfig represent the kubernetes configuration file
 var Kubeconfig string
@@ -60,65 +75,483 @@ var Kubeconfig string
 //OutputFolder represent the kubernetes configuration file
 var OutputFolder string
 
-//Logs
+//Logs stream the logs of the containers of the captured pods during the capture
 var Logs bool
 
+//PreviousLogs also save the logs of the previous instance of the containers which restarted
+var PreviousLogs bool
+
+//Selector represent the label selector used to pick the pods to capture
+var Selector string
+
+//FieldSelector represent the field selector used to pick the pods to capture
+var FieldSelector string
+
+//Pods represent the names of the pods to capture
+var Pods []string
+
+//PodRegex represent the regular expression matching the names of the pods to capture
+var PodRegex string
+
+//Follow start capturing the matching pods appearing during the capture
+var Follow bool
+
+//Duration stop the capture after the given time
+var Duration time.Duration
+
+//MaxPackets stop the capture once the total number of packets is reached
+var MaxPackets uint64
+
+//MaxBytes stop the capture once the total number of bytes is reached
+var MaxBytes byteSize
+
+//MaxPodPackets stop the capture of a pod once its number of packets is reached
+var MaxPodPackets uint64
+
+//MaxPodBytes stop the capture of a pod once its number of bytes is reached
+var MaxPodBytes byteSize
+
+//RotateSize start a new pcap file once the current one reaches this size
+var RotateSize byteSize
+
+//RotateInterval start a new pcap file once the current one is older than the interval
+var RotateInterval time.Duration
+
+//RingFiles keep only the last files of each pcap rotation
+var RingFiles int
+
+//Format is the format of the capture files, pcap or pcapng
+var Format string
+
+//BPFFilter represent the BPF expression applied by the capture pods, in tcpdump syntax
+var BPFFilter string
+
+//WriteFilter select the packets written to the pcap files, as a display filter or a BPF expression
+var WriteFilter string
+
+//DisplayFilter select the packets printed on the console, as a display filter or a BPF expression
+var DisplayFilter string
+
+//OutputFormat is the format of the packets printed on the console
+var OutputFormat string
+
+//Write is where the merged capture is streamed live, - meaning stdout
+var Write string
+
+//Fifo is a named pipe created to stream the merged capture live
+var Fifo string
+
+//MergeWindow is the time packets are held to be written to the merged files in the order of their timestamps
+var MergeWindow time.Duration
+
+//MergeBuffer is the number of packets waiting to be written to the merged files before new ones are dropped
+var MergeBuffer int
+
+//Snaplen is the snapshot length asked to the capture pods, zero letting them choose it
+var Snaplen uint32
+
+//ReconnectAttempts is the number of times a dropped capture connection is retried, zero never reconnecting
+var ReconnectAttempts int
+
+//ReconnectMaxDelay is the longest time waited between two reconnection attempts
+var ReconnectMaxDelay time.Duration
+
+//Connect is the way the kpture proxy is reached: auto, nodeport or port-forward
+var Connect string
+
+//EnableTLS connect to the kpture proxy over TLS, with the certificates of the TLS secret unless given by the flags
+var EnableTLS bool
+
+//TLSCA is the certificate authority verifying the kpture proxy, read from the TLS secret when empty
+var TLSCA string
+
+//TLSCert and TLSKey are the client certificate and key authenticating to the kpture proxy
+var TLSCert, TLSKey string
+
+//Token is the bearer token authenticating to the kpture proxy, the kubeconfig token being used when empty
+var Token string
+
+//Interfaces are the interfaces captured in each pod, all selecting every interface of the pod network status
+var Interfaces []string
+
+//ClusterEvents record the kubernetes events and the status changes of the captured pods and of their nodes
+var ClusterEvents bool
+
+//JSONL is the file receiving the metadata of the captured packets as JSON lines, - meaning stdout
+var JSONL string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "kpture",
+	Use:   "kpture [kind/name ...]",
 	Short: "Packet capture for kubernetes",
 	Long: `Kpture is a packet capture tool for kubernetes, it consist of a capture pod on each nodes (daemonset)
 which samples packets on desired pods and send the captured informations back via TCP socket.
+
+Targets can be given as workloads (deployment/foo, sts/db, ds/agent, job/batch, svc/bar), in which case
+every running pod of the workload is captured and an additional merged pcap is written per workload.
+Workloads can be qualified with their namespace (backend/deployment/api), otherwise the first namespace is used.
 	`,
+	Args: cobra.ArbitraryArgs,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
+		if BPFFilter != "" {
+			_, err := filter.CompileBPF(BPFFilter, layers.LinkTypeEthernet, pcapfile.DefaultSnaplen)
+			if errors.Is(err, filter.ErrUnsupported) {
+				// The capture pods compile the filter with libpcap, which knows more of the syntax than kpture
+				fmt.Fprintf(os.Stderr, "filter %q can't be checked locally (%s), it is passed to the capture pods as is\n", BPFFilter, err)
+			} else if err != nil {
+				cobra.CheckErr(fmt.Errorf("invalid filter %q: %w", BPFFilter, err))
+			}
+		}
+		writeFilter, err := parseFilter(WriteFilter)
+		cobra.CheckErr(err)
+		displayFilter, err := parseFilter(DisplayFilter)
+		cobra.CheckErr(err)
+		if JSONL == "-" && cmd.Flags().Changed("output-format") && OutputFormat != display.FormatJSON {
+			cobra.CheckErr(fmt.Errorf("--output-format %s can't be used with --jsonl -, which prints the packets as JSON lines", OutputFormat))
+		}
+		// The live capture and the JSON lines take stdout over, the human output goes to stderr
+		var stdout io.WriteCloser = os.Stdout
+		if Write == "-" || JSONL == "-" {
+			if Write == JSONL {
+				cobra.CheckErr(errors.New("-w - and --jsonl can't both write to stdout"))
+			}
+			os.Stdout = os.Stderr
+		}
+		// A viewer closing the live capture must not kill the session
+		signal.Ignore(syscall.SIGPIPE)
+
+		var printer *display.Printer
+		if JSONL != "-" {
+			printer, err = display.NewPrinter(os.Stdout, OutputFormat)
+			cobra.CheckErr(err)
+		}
+
 		client, err := kubernetes.LoadClient(Kubeconfig)
 		cobra.CheckErr(err)
 		config, err := kubernetes.LoadConfig(Kubeconfig)
 		cobra.CheckErr(err)
-		pods, dial := kubernetes.SelectPod(client, Namespace, config)
+		podFilter := kubernetes.PodFilter{LabelSelector: Selector, FieldSelector: FieldSelector, Names: Pods, NameRegex: PodRegex}
+
+		workloads := []*kubernetes.Workload{}
+		for _, arg := range args {
+			w, err := kubernetes.ResolveWorkload(client, Namespaces[0], arg)
+			cobra.CheckErr(err)
+			workloads = append(workloads, w)
+		}
 
-		if len(pods) == 0 {
+		pods := []v1.Pod{}
+		if len(workloads) == 0 || !podFilter.IsEmpty() {
+			pods, err = kubernetes.SelectPod(client, namespaces(), podFilter)
+			cobra.CheckErr(err)
+		}
+
+		// Each pod is captured once, and written to the merged file of every workload it belongs to
+		groups := map[string][]*kubernetes.Workload{}
+		for _, w := range workloads {
+			wpods, err := w.Pods(client)
+			cobra.CheckErr(err)
+			if len(wpods) == 0 {
+				fmt.Println("No running pod found for", w)
+			}
+			for _, pod := range wpods {
+				if _, ok := groups[podKey(pod)]; !ok && !containsPod(pods, pod) {
+					pods = append(pods, pod)
+				}
+				groups[podKey(pod)] = append(groups[podKey(pod)], w)
+			}
+		}
+
+		if Follow && len(workloads) == 0 && podFilter.IsEmpty() {
+			cobra.CheckErr(errors.New("--follow requires workloads or a pod selection flag"))
+		}
+		if len(pods) == 0 && !Follow {
 			return
 		}
 
-		err = os.Mkdir(OutputFolder, os.ModePerm)
+		dial, forwarder, err := kubernetes.ProxyAddress(client, config, Connect)
+		cobra.CheckErr(err)
+		if forwarder != nil {
+			defer forwarder.Close()
+		}
+		tlsConfig, err := proxyTLS(client)
+		cobra.CheckErr(err)
+		token, err := proxyToken(config, tlsConfig != nil)
+		cobra.CheckErr(err)
+
+		err = os.MkdirAll(OutputFolder, os.ModePerm)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
 
-		f, err := os.Create(OutputFolder + "/merged.pcap")
-		if err != nil {
+		fileOptions := pcapfile.Options{
+			Format:         Format,
+			Comment:        fmt.Sprintf("kpture capture started at %s", time.Now().Format(time.RFC3339)),
+			Snaplen:        Snaplen,
+			LinkType:       layers.LinkTypeEthernet,
+			RotateSize:     int64(RotateSize),
+			RotateInterval: RotateInterval,
+			RingFiles:      RingFiles,
+		}
+		cobra.CheckErr(fileOptions.Validate())
+
+		recorder, err := session.NewRecorder(OutputFolder)
+		cobra.CheckErr(err)
+
+		// The JSON lines hold the packets written to the files, whether they go to stdout or to a file
+		var events *display.Printer
+		switch JSONL {
+		case "":
+		case "-":
+			events, err = display.NewPrinter(stdout, display.FormatJSON)
+			cobra.CheckErr(err)
+		default:
+			f, err := os.Create(JSONL)
+			cobra.CheckErr(err)
+			defer f.Close()
+			events, err = display.NewPrinter(f, display.FormatJSON)
+			cobra.CheckErr(err)
+		}
+
+		sigctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := context.WithCancel(sigctx)
+		defer cancel()
+
+		// The first reason to stop the session wins and is reported in the summary
+		var once sync.Once
+		reason := ""
+		end := func(r string) {
+			once.Do(func() {
+				reason = r
+				cancel()
+			})
+		}
+		if Duration > 0 {
+			timer := time.AfterFunc(Duration, func() { end("duration reached") })
+			defer timer.Stop()
+		}
+
+		start := time.Now()
+		s := &captureSession{
+			ctx:           ctx,
+			client:        client,
+			dial:          dial,
+			folder:        OutputFolder,
+			workloads:     workloads,
+			workloadFiles: map[*kubernetes.Workload]*pcapfile.File{},
+			fileOptions:   fileOptions,
+			bpfFilter:     BPFFilter,
+			writeFilter:   writeFilter,
+			displayFilter: displayFilter,
+			printer:       printer,
+			merger:        merge.NewWriter(MergeBuffer, MergeWindow),
+			streamer:      merge.NewWriter(MergeBuffer, 0),
+			events:        events,
+			recorder:      recorder,
+			streams:       map[string][]*socket.Stream{},
+			podFiles:      map[string]*pcapfile.File{},
+			interfaces:    Interfaces,
+			limits:        socket.Limits{Packets: MaxPodPackets, Bytes: uint64(MaxPodBytes)},
+			reconnect:     socket.Backoff{Delay: socket.DefaultBackoff.Delay, MaxDelay: ReconnectMaxDelay, Attempts: ReconnectAttempts},
+			tls:           tlsConfig,
+			token:         token,
+		}
+		global := socket.Limits{Packets: MaxPackets, Bytes: uint64(MaxBytes)}
+		if !global.IsZero() {
+			s.counter = &socket.Counter{Limits: global, OnLimit: end}
+		}
+		s.merged, err = s.createPcap(OutputFolder+"/merged"+fileOptions.Extension(), fileOptions)
+		cobra.CheckErr(err)
+		if Write != "" {
+			out := stdout
+			if Write != "-" {
+				out, err = os.Create(Write)
+				cobra.CheckErr(err)
+			}
+			cobra.CheckErr(s.addLive(out))
+		}
+		if Fifo != "" {
+			created, err := mkfifo(Fifo)
+			cobra.CheckErr(err)
+			if created {
+				defer os.Remove(Fifo)
+			}
+			fmt.Println("Waiting for a reader on", Fifo)
+			out, err := openFifo(sigctx, Fifo)
+			if err != nil && created {
+				// CheckErr exits without running the deferred calls
+				os.Remove(Fifo)
+			}
+			cobra.CheckErr(err)
+			cobra.CheckErr(s.addLive(out))
+		}
+		for _, w := range workloads {
+			err = os.MkdirAll(OutputFolder+"/"+w.Namespace, os.ModePerm)
+			cobra.CheckErr(err)
+			s.workloadFiles[w], err = s.createPcap(OutputFolder+"/"+w.Namespace+"/"+w.Kind+"-"+w.Name+fileOptions.Extension(), fileOptions)
+			cobra.CheckErr(err)
+		}
+		if !podFilter.IsEmpty() {
+			s.filter, err = podFilter.Matcher()
 			cobra.CheckErr(err)
 		}
-		wf := pcapgo.NewWriter(f)
-		wf.WriteFileHeader(1024, layers.LinkTypeEthernet)
 
-		podLogOpts := v1.PodLogOptions{}
 		if Logs {
-			podLogOpts = v1.PodLogOptions{SinceTime: &metav1.Time{Time: time.Now()}}
-			c := make(chan os.Signal)
-			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-			go func() {
-				<-c
-				kubernetes.GetLogs(client, Namespace, pods, podLogOpts, OutputFolder)
-				os.Exit(1)
-			}()
+			s.logs = kubernetes.NewLogCollector(client, OutputFolder, start, PreviousLogs)
+		}
+		if ClusterEvents {
+			s.clusterEvents, err = session.NewClusterRecorder(OutputFolder)
+			cobra.CheckErr(err)
+			if fileOptions.Format == pcapfile.FormatPcapng {
+				cobra.CheckErr(s.addMarkers())
+			}
+			filter := kubernetes.ClusterFilter{Pod: s.captured, Node: s.capturedNode}
+			kubernetes.WatchCluster(client, watchedNamespaces(workloads), start, filter, s.OnClusterEvent, ctx.Done())
 		}
 		for _, pod := range pods {
-			err = os.Mkdir(OutputFolder+"/"+pod, os.ModePerm)
-			if err != nil {
-				cobra.CheckErr(err)
+			s.start(pod, groups[podKey(pod)])
+		}
+
+		var done <-chan struct{}
+		if Follow {
+			options := metav1.ListOptions{}
+			if len(workloads) == 0 {
+				options = podFilter.ListOptions()
 			}
-			socket.StartCapture(socket.Capture{ContainerName: pod, ContainerNamespace: Namespace, Interface: "eth0", FileName: OutputFolder + "/" + pod + "/" + pod + ".pcap"}, dial, wf)
+			for _, namespace := range watchedNamespaces(workloads) {
+				kubernetes.WatchPods(client, namespace, options, s.match, s, ctx.Done())
+			}
+		} else {
+			done = s.idle()
+		}
+
+		select {
+		case <-ctx.Done():
+			end("interrupted")
+		case <-done:
+			end("every capture ended")
 		}
-		for {
+		fmt.Println("Stopping the capture:", reason)
+		// A second signal kills the process without waiting for the files to be flushed
+		stop()
 
+		summary := s.shutdown(start, reason)
+		if err := summary.Write(OutputFolder); err != nil {
+			fmt.Println(err)
 		}
+		summary.Print(os.Stdout)
 	},
 }
 
+//proxyToken return the bearer token sent to the proxy over TLS, --token or the token of the kubeconfig.
+//Kubeconfigs authenticating with an exec or auth-provider plugin hold no token, one must then be given with --token
+func proxyToken(config *rest.Config, useTLS bool) (string, error) {
+	if Token != "" || !useTLS {
+		return Token, nil
+	}
+	if config.BearerToken != "" {
+		return config.BearerToken, nil
+	}
+	if config.BearerTokenFile != "" {
+		b, err := os.ReadFile(config.BearerTokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return "", errors.New("the kubeconfig holds no bearer token to authenticate to the proxy, such as with exec or auth-provider credentials: give one with --token")
+}
+
+//proxyTLS return the configuration of the connections to the kpture proxy, from the flags or from the TLS secret.
+//Captures are sent over plain TCP unless --tls or --tls-ca is set, the default proxy image serving plain TCP only
+func proxyTLS(client *k8s.Clientset) (*tls.Config, error) {
+	if TLSCA != "" {
+		ca, err := os.ReadFile(TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		var cert, key []byte
+		if TLSCert != "" || TLSKey != "" {
+			if cert, err = os.ReadFile(TLSCert); err != nil {
+				return nil, err
+			}
+			if key, err = os.ReadFile(TLSKey); err != nil {
+				return nil, err
+			}
+		}
+		return socket.ClientTLS(ca, cert, key)
+	}
+	if TLSCert != "" || TLSKey != "" {
+		return nil, errors.New("--tls-cert and --tls-key require --tls-ca")
+	}
+	if !EnableTLS {
+		return nil, nil
+	}
+
+	secret, err := kubernetes.LoadTLSSecret(client)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, errors.New("no kpture-tls secret found, install kpture with --tls or give the certificates with --tls-ca")
+	}
+	return socket.ClientTLS(secret[socket.TLSCAKey], secret[socket.TLSClientCertKey], secret[socket.TLSClientKeyKey])
+}
+
+//parseFilter compile a client side filter, an empty expression keeping every packet
+func parseFilter(expr string) (*filter.Filter, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	f, err := filter.Parse(expr, layers.LinkTypeEthernet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+	}
+	return f, nil
+}
+
+//namespaces return the namespaces to select pods from, nil meaning all namespaces
+func namespaces() []string {
+	if AllNamespaces {
+		return nil
+	}
+	return Namespaces
+}
+
+//watchedNamespaces return the namespaces to run a pod informer on when following
+func watchedNamespaces(workloads []*kubernetes.Workload) []string {
+	if AllNamespaces {
+		return []string{metav1.NamespaceAll}
+	}
+	watched := append([]string{}, Namespaces...)
+	for _, w := range workloads {
+		if !contains(watched, w.Namespace) {
+			watched = append(watched, w.Namespace)
+		}
+	}
+	return watched
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPod(pods []v1.Pod, pod v1.Pod) bool {
+	for _, p := range pods {
+		if podKey(p) == podKey(pod) {
+			return true
+		}
+	}
+	return false
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -135,9 +568,45 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.kpture.yaml)")
-	rootCmd.PersistentFlags().StringVarP(&Namespace, "namespace", "n", "default", "kubernetes namespace")
+	rootCmd.PersistentFlags().StringSliceVarP(&Namespaces, "namespace", "n", []string{"default"}, "kubernetes namespace (repeatable)")
+	rootCmd.PersistentFlags().BoolVarP(&AllNamespaces, "all-namespaces", "A", false, "select pods in every namespace")
 
-	rootCmd.Flags().BoolVarP(&Logs, "logs", "l", false, "fetch container logs as well")
+	rootCmd.Flags().BoolVarP(&Logs, "logs", "l", false, "stream the logs of every container of the captured pods, with timestamps, to <pod folder>/<container>.log")
+	rootCmd.Flags().BoolVar(&ClusterEvents, "events", false, "record the kubernetes events and status changes of the captured pods and their nodes to events.jsonl, and as markers of the merged pcapng files")
+	rootCmd.Flags().BoolVar(&PreviousLogs, "previous-logs", false, "with --logs, also save the logs of the previous instance of restarted containers to <container>-previous.log")
+	rootCmd.Flags().StringVar(&Selector, "selector", "", "label selector of the pods to capture, skips the interactive prompt")
+	rootCmd.Flags().StringVar(&FieldSelector, "field-selector", "", "field selector of the pods to capture, skips the interactive prompt")
+	rootCmd.Flags().StringArrayVarP(&Pods, "pod", "p", []string{}, "name of a pod to capture (repeatable), skips the interactive prompt")
+	rootCmd.Flags().BoolVarP(&Follow, "follow", "f", false, "capture the matching pods starting while the capture is running")
+	rootCmd.Flags().DurationVar(&Duration, "duration", 0, "stop the capture after this duration (e.g. 30s, 5m)")
+	rootCmd.Flags().Uint64Var(&MaxPackets, "max-packets", 0, "stop the capture after this number of packets")
+	rootCmd.Flags().Var(&MaxBytes, "max-bytes", "stop the capture after this amount of bytes (e.g. 500M)")
+	rootCmd.Flags().Uint64Var(&MaxPodPackets, "max-packets-per-pod", 0, "stop the capture of a pod after this number of packets")
+	rootCmd.Flags().Var(&MaxPodBytes, "max-bytes-per-pod", "stop the capture of a pod after this amount of bytes (e.g. 100M)")
+	rootCmd.Flags().StringVar(&Format, "format", pcapfile.FormatPcap, "format of the capture files, pcap or pcapng (one interface per pod in merged files)")
+	rootCmd.Flags().Var(&RotateSize, "rotate-size", "start a new pcap file once the current one reaches this size (e.g. 100M)")
+	rootCmd.Flags().DurationVar(&RotateInterval, "rotate-interval", 0, "start a new pcap file once the current one is older than this duration")
+	rootCmd.Flags().IntVar(&RingFiles, "ring-files", 0, "keep only the last N files of each pod and of the merged capture, requires a rotation")
+	rootCmd.Flags().StringVar(&BPFFilter, "filter", "", "BPF expression applied by the capture pods, in tcpdump syntax (e.g. \"tcp port 80\")")
+	rootCmd.Flags().StringVar(&WriteFilter, "write-filter", "", "display filter or BPF expression selecting the packets written to the pcap files (e.g. \"tcp.port == 443\")")
+	rootCmd.Flags().StringVarP(&DisplayFilter, "display-filter", "Y", "", "display filter or BPF expression selecting the packets printed on the console (e.g. \"dns.qname contains api\")")
+	rootCmd.Flags().StringVar(&OutputFormat, "output-format", display.FormatText, "format of the packets printed on the console: text, json, quiet or verbose")
+	rootCmd.Flags().StringVarP(&Write, "write", "w", "", "stream the merged capture live to this file, or to stdout with - (e.g. -w - | wireshark -k -i -)")
+	rootCmd.Flags().StringVar(&Fifo, "fifo", "", "create a named pipe streaming the merged capture live (e.g. wireshark -k -i /tmp/kpture)")
+	rootCmd.Flags().IntVar(&MergeBuffer, "merge-buffer", merge.DefaultBuffer, "number of packets waiting to be written to the merged files before new ones are dropped")
+	rootCmd.Flags().DurationVar(&MergeWindow, "merge-window", merge.DefaultWindow, "time packets are held to be written to the merged files in the order of their timestamps, 0 to write them as they arrive")
+	rootCmd.Flags().Uint32Var(&Snaplen, "snaplen", 0, "largest number of bytes captured per packet, 0 letting the capture pods choose it (up to 262144)")
+	rootCmd.Flags().IntVar(&ReconnectAttempts, "reconnect-attempts", socket.DefaultBackoff.Attempts, "number of attempts to reopen a capture whose connection dropped, 0 to never reconnect")
+	rootCmd.Flags().DurationVar(&ReconnectMaxDelay, "reconnect-max-delay", socket.DefaultBackoff.MaxDelay, "longest time waited between two reconnection attempts, the delay doubling after each attempt")
+	rootCmd.Flags().StringVar(&Connect, "connect", kubernetes.ConnectAuto, "how to reach the kpture proxy: nodeport, port-forward through the API server, or auto to use port-forward when the NodePort is unreachable")
+	rootCmd.Flags().BoolVar(&EnableTLS, "tls", false, "connect to the kpture proxy over TLS, for proxies installed with kpture install --tls")
+	rootCmd.Flags().StringVar(&TLSCA, "tls-ca", "", "certificate authority of the kpture proxy, read from the kpture-tls secret by default")
+	rootCmd.Flags().StringVar(&TLSCert, "tls-cert", "", "client certificate authenticating to the kpture proxy")
+	rootCmd.Flags().StringVar(&TLSKey, "tls-key", "", "key of the client certificate")
+	rootCmd.Flags().StringVar(&Token, "token", "", "bearer token authenticating to the kpture proxy, validated with a TokenReview (default is the kubeconfig token)")
+	rootCmd.Flags().StringArrayVarP(&Interfaces, "interface", "i", []string{kubernetes.DefaultInterface}, "interface captured in each pod (repeatable), all for every interface of the Multus network status")
+	rootCmd.Flags().StringVar(&JSONL, "jsonl", "", "write the metadata of the captured packets as JSON lines to this file, or to stdout with - (e.g. --jsonl - | jq)")
+	rootCmd.Flags().StringVar(&PodRegex, "pod-regex", "", "regular expression matching the names of the pods to capture, skips the interactive prompt")
 
 	home, err := homedir.Dir()
 	cobra.CheckErr(err)
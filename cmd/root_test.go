@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestProxyToken(t *testing.T) {
+	defer func(token string) { Token = token }(Token)
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		flag   string
+		config rest.Config
+		tls    bool
+		token  string
+		valid  bool
+	}{
+		{"flag", "from-flag", rest.Config{BearerToken: "from-config"}, true, "from-flag", true},
+		{"kubeconfig", "", rest.Config{BearerToken: "from-config"}, true, "from-config", true},
+		{"token file", "", rest.Config{BearerTokenFile: file}, true, "from-file", true},
+		{"exec plugin", "", rest.Config{ExecProvider: &clientcmdapi.ExecConfig{Command: "aws"}}, true, "", false},
+		{"missing token file", "", rest.Config{BearerTokenFile: file + "-missing"}, true, "", false},
+		{"plain tcp", "", rest.Config{}, false, "", true},
+	}
+	for _, tt := range tests {
+		Token = tt.flag
+		token, err := proxyToken(&tt.config, tt.tls)
+		if (err == nil) != tt.valid || token != tt.token {
+			t.Errorf("%s: proxyToken() = %q, %v, want %q, valid %v", tt.name, token, err, tt.token, tt.valid)
+		}
+	}
+}
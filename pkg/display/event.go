@@ -0,0 +1,131 @@
+package display
+
+import (
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+//Source identify the pod a packet was captured on
+type Source struct {
+	Namespace string
+	Pod       string
+	Node      string
+	//Interface is only set when several interfaces of the pod are captured
+	Interface string
+}
+
+//String return the namespace and the name of the pod, followed by the interface when set
+func (s Source) String() string {
+	if s.Interface != "" {
+		return s.Namespace + "/" + s.Pod + "/" + s.Interface
+	}
+	return s.Namespace + "/" + s.Pod
+}
+
+//DNSQuestion is a question of a DNS message
+type DNSQuestion struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+//DNSEvent summarize a DNS message
+type DNSEvent struct {
+	ID        uint16        `json:"id"`
+	Response  bool          `json:"response"`
+	Rcode     string        `json:"rcode,omitempty"`
+	Questions []DNSQuestion `json:"questions,omitempty"`
+	Answers   []string      `json:"answers,omitempty"`
+}
+
+//HTTPEvent summarize an HTTP request
+type HTTPEvent struct {
+	Method string `json:"method"`
+	URI    string `json:"uri"`
+	Host   string `json:"host,omitempty"`
+}
+
+//PacketEvent is the metadata of a packet, written as a JSON line
+type PacketEvent struct {
+	Time          time.Time  `json:"time"`
+	Namespace     string     `json:"namespace"`
+	Pod           string     `json:"pod"`
+	Node          string     `json:"node,omitempty"`
+	Interface     string     `json:"interface,omitempty"`
+	SrcIP         string     `json:"src_ip,omitempty"`
+	DstIP         string     `json:"dst_ip,omitempty"`
+	SrcPort       uint16     `json:"src_port,omitempty"`
+	DstPort       uint16     `json:"dst_port,omitempty"`
+	Transport     string     `json:"transport,omitempty"`
+	Protocol      string     `json:"protocol"`
+	CaptureLength int        `json:"capture_length"`
+	Length        int        `json:"length"`
+	TCPFlags      []string   `json:"tcp_flags,omitempty"`
+	Info          string     `json:"info,omitempty"`
+	DNS           *DNSEvent  `json:"dns,omitempty"`
+	HTTP          *HTTPEvent `json:"http,omitempty"`
+}
+
+//LogEvent is a line logged by a container, written as a JSON line
+type LogEvent struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	Log       string    `json:"log"`
+}
+
+//NewPacketEvent decode the metadata of a packet captured on a pod
+func NewPacketEvent(src Source, p gopacket.Packet) PacketEvent {
+	s := Summarize(src.String(), p)
+	e := PacketEvent{
+		Time:          s.Time,
+		Namespace:     src.Namespace,
+		Pod:           src.Pod,
+		Node:          src.Node,
+		Interface:     src.Interface,
+		Protocol:      s.Protocol,
+		CaptureLength: len(p.Data()),
+		Length:        s.Length,
+		Info:          s.Info,
+	}
+
+	switch ip := p.NetworkLayer().(type) {
+	case *layers.IPv4:
+		e.SrcIP, e.DstIP = ip.SrcIP.String(), ip.DstIP.String()
+	case *layers.IPv6:
+		e.SrcIP, e.DstIP = ip.SrcIP.String(), ip.DstIP.String()
+	}
+	switch t := p.TransportLayer().(type) {
+	case *layers.TCP:
+		e.SrcPort, e.DstPort, e.Transport = uint16(t.SrcPort), uint16(t.DstPort), "tcp"
+		e.TCPFlags = TCPFlags(t)
+	case *layers.UDP:
+		e.SrcPort, e.DstPort, e.Transport = uint16(t.SrcPort), uint16(t.DstPort), "udp"
+	case *layers.SCTP:
+		e.SrcPort, e.DstPort, e.Transport = uint16(t.SrcPort), uint16(t.DstPort), "sctp"
+	}
+
+	if dns, ok := p.Layer(layers.LayerTypeDNS).(*layers.DNS); ok {
+		e.DNS = &DNSEvent{ID: dns.ID, Response: dns.QR}
+		if dns.QR {
+			e.DNS.Rcode = dns.ResponseCode.String()
+		}
+		for _, q := range dns.Questions {
+			e.DNS.Questions = append(e.DNS.Questions, DNSQuestion{Name: string(q.Name), Type: q.Type.String()})
+		}
+		for _, a := range dns.Answers {
+			switch {
+			case a.IP != nil:
+				e.DNS.Answers = append(e.DNS.Answers, a.IP.String())
+			case len(a.CNAME) > 0:
+				e.DNS.Answers = append(e.DNS.Answers, string(a.CNAME))
+			}
+		}
+	}
+	if method, uri, host, ok := HTTPRequest(p); ok {
+		e.HTTP = &HTTPEvent{Method: method, URI: uri, Host: host}
+	}
+	return e
+}
@@ -0,0 +1,35 @@
+package display
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+func TestNewPacketEvent(t *testing.T) {
+	src := Source{Namespace: "default", Pod: "web", Node: "node-1"}
+	tests := []struct {
+		name  string
+		event PacketEvent
+		want  string
+	}{
+		{"tcp", NewPacketEvent(Source{Namespace: "default", Pod: "web", Interface: "net1"}, tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 5432, SYN: true, ACK: true}, "")),
+			`{"time":"2021-06-11T10:04:05.123456Z","namespace":"default","pod":"web","interface":"net1","src_ip":"10.0.0.1","dst_ip":"10.0.0.2","src_port":40000,"dst_port":5432,"transport":"tcp","protocol":"TCP","capture_length":60,"length":60,"tcp_flags":["SYN","ACK"],"info":"[SYN,ACK] Seq=0 Ack=0 Win=0 Len=0"}`},
+		{"dns", NewPacketEvent(src, dnsPacket(t, dnsReply)),
+			`{"time":"2021-06-11T10:04:05.123456Z","namespace":"default","pod":"web","node":"node-1","src_ip":"10.0.0.1","dst_ip":"10.0.0.2","src_port":53,"dst_port":40000,"transport":"udp","protocol":"DNS","capture_length":150,"length":150,"info":"response 0x1a2b A db.default.svc CNAME db-0.default.svc A 10.0.0.2","dns":{"id":6699,"response":true,"rcode":"No Error","questions":[{"name":"db.default.svc","type":"A"}],"answers":["db-0.default.svc","10.0.0.2"]}}`},
+		{"dns query", NewPacketEvent(src, dnsPacket(t, dnsQuery)),
+			`{"time":"2021-06-11T10:04:05.123456Z","namespace":"default","pod":"web","node":"node-1","src_ip":"10.0.0.1","dst_ip":"10.0.0.2","src_port":53,"dst_port":40000,"transport":"udp","protocol":"DNS","capture_length":74,"length":74,"info":"query 0x1a2b A db.default.svc","dns":{"id":6699,"response":false,"questions":[{"name":"db.default.svc","type":"A"}]}}`},
+		{"http", NewPacketEvent(src, tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 80, PSH: true, ACK: true}, "GET /index.html HTTP/1.1\r\nHost: web\r\n\r\n")),
+			`{"time":"2021-06-11T10:04:05.123456Z","namespace":"default","pod":"web","node":"node-1","src_ip":"10.0.0.1","dst_ip":"10.0.0.2","src_port":40000,"dst_port":80,"transport":"tcp","protocol":"HTTP","capture_length":93,"length":93,"tcp_flags":["PSH","ACK"],"info":"GET /index.html web","http":{"method":"GET","uri":"/index.html","host":"web"}}`},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s: NewPacketEvent() =\n%s, want\n%s", tt.name, b, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/gopacket"
+)
+
+//Console output formats
+const (
+	FormatText    = "text"
+	FormatJSON    = "json"
+	FormatQuiet   = "quiet"
+	FormatVerbose = "verbose"
+)
+
+//Formats list the supported console output formats
+var Formats = []string{FormatText, FormatJSON, FormatQuiet, FormatVerbose}
+
+//podColors are given to the pods after the hash of their name, so that a pod keeps its color
+var podColors = []color.Attribute{color.FgGreen, color.FgCyan, color.FgHiYellow, color.FgMagenta, color.FgBlue, color.FgHiGreen, color.FgHiCyan, color.FgHiMagenta}
+
+//Printer write the packets of every pod to the console, it is safe for concurrent use
+type Printer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+	enc    *json.Encoder
+}
+
+//NewPrinter return a printer writing to w in the given format
+func NewPrinter(w io.Writer, format string) (*Printer, error) {
+	switch format {
+	case FormatText, FormatJSON, FormatQuiet, FormatVerbose:
+	default:
+		return nil, fmt.Errorf("unsupported output format %q, expected one of %v", format, Formats)
+	}
+	return &Printer{w: w, format: format, enc: json.NewEncoder(w)}, nil
+}
+
+func podColor(pod string) *color.Color {
+	h := fnv.New32a()
+	h.Write([]byte(pod))
+	return color.New(podColors[h.Sum32()%uint32(len(podColors))])
+}
+
+//Print write a packet captured on a pod, a nil printer printing nothing.
+//The json format write the metadata of the packet as a JSON line
+func (p *Printer) Print(src Source, packet gopacket.Packet) {
+	if p == nil || p.format == FormatQuiet {
+		return
+	}
+	if p.format == FormatJSON {
+		event := NewPacketEvent(src, packet)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err := p.enc.Encode(event); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	pod := src.String()
+	s := Summarize(pod, packet)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.format {
+	case FormatVerbose:
+		podColor(pod).Fprintf(p.w, "%s %s\n", s.Time.Format("15:04:05.000000"), pod)
+		fmt.Fprintln(p.w, packet)
+	default:
+		arrow := ""
+		if s.Src != "" || s.Dst != "" {
+			arrow = s.Src + " → " + s.Dst
+		}
+		fmt.Fprintf(p.w, "%s %s %s %s %d %s\n", s.Time.Format("15:04:05.000000"), podColor(pod).Sprint(pod), arrow, s.Protocol, s.Length, s.Info)
+	}
+}
+
+//PrintLog write a line logged by a container of a pod, a nil printer printing nothing
+func (p *Printer) PrintLog(src Source, container string, t time.Time, line string) {
+	if p == nil || p.format == FormatQuiet {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.format == FormatJSON {
+		if err := p.enc.Encode(LogEvent{Time: t, Namespace: src.Namespace, Pod: src.Pod, Container: container, Log: line}); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+	pod := src.Namespace + "/" + src.Pod
+	fmt.Fprintf(p.w, "%s %s [%s] %s\n", t.Format("15:04:05.000000"), podColor(pod).Sprint(pod), container, line)
+}
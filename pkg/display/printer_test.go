@@ -0,0 +1,58 @@
+package display
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/gopacket/layers"
+)
+
+func TestPrinter(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	src := Source{Namespace: "default", Pod: "web"}
+	packet := tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 5432, SYN: true}, "")
+	logged := time.Date(2021, 6, 11, 10, 4, 6, 0, time.UTC)
+	tests := []struct {
+		format string
+		// want is the output of a packet followed by a log line, verbose only checking its prefix
+		want string
+	}{
+		{FormatText, "10:04:05.123456 default/web 10.0.0.1:40000 → 10.0.0.2:5432 TCP 60 [SYN] Seq=0 Ack=0 Win=0 Len=0\n" +
+			"10:04:06.000000 default/web [nginx] GET / 200\n"},
+		{FormatJSON, `{"time":"2021-06-11T10:04:05.123456Z","namespace":"default","pod":"web","src_ip":"10.0.0.1","dst_ip":"10.0.0.2","src_port":40000,"dst_port":5432,"transport":"tcp","protocol":"TCP","capture_length":60,"length":60,"tcp_flags":["SYN"],"info":"[SYN] Seq=0 Ack=0 Win=0 Len=0"}` + "\n" +
+			`{"time":"2021-06-11T10:04:06Z","namespace":"default","pod":"web","container":"nginx","log":"GET / 200"}` + "\n"},
+		{FormatQuiet, ""},
+		{FormatVerbose, "10:04:05.123456 default/web\nPACKET: 60 bytes"},
+	}
+	for _, tt := range tests {
+		out := &bytes.Buffer{}
+		p, err := NewPrinter(out, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Print(src, packet)
+		p.PrintLog(src, "nginx", logged, "GET / 200")
+		if tt.format == FormatVerbose {
+			if !strings.HasPrefix(out.String(), tt.want) || !strings.HasSuffix(out.String(), "10:04:06.000000 default/web [nginx] GET / 200\n") {
+				t.Errorf("verbose output:\n%s", out)
+			}
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", tt.format, out, tt.want)
+		}
+	}
+
+	if _, err := NewPrinter(&bytes.Buffer{}, "yaml"); err == nil {
+		t.Error("NewPrinter() accepted an unknown format")
+	}
+	// A nil printer prints nothing
+	var p *Printer
+	p.Print(src, packet)
+	p.PrintLog(src, "nginx", logged, "GET / 200")
+}
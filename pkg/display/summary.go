@@ -0,0 +1,157 @@
+package display
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+//Summary is the one line description of a packet
+type Summary struct {
+	Time     time.Time `json:"time"`
+	Pod      string    `json:"pod"`
+	Src      string    `json:"src"`
+	Dst      string    `json:"dst"`
+	Protocol string    `json:"protocol"`
+	Length   int       `json:"length"`
+	Info     string    `json:"info,omitempty"`
+}
+
+//HTTPRequest parse the request line and the host header of an HTTP request carried by a TCP segment
+func HTTPRequest(p gopacket.Packet) (method, uri, host string, ok bool) {
+	tcp, isTCP := p.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !isTCP || len(tcp.Payload) == 0 {
+		return "", "", "", false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(tcp.Payload))
+	if !scanner.Scan() {
+		return "", "", "", false
+	}
+	request := strings.Fields(scanner.Text())
+	if len(request) != 3 || !strings.HasPrefix(request[2], "HTTP/1.") {
+		return "", "", "", false
+	}
+	for scanner.Scan() && scanner.Text() != "" {
+		header := strings.SplitN(scanner.Text(), ":", 2)
+		if len(header) == 2 && strings.EqualFold(header[0], "host") {
+			host = strings.TrimSpace(header[1])
+		}
+	}
+	return request[0], request[1], host, true
+}
+
+//TCPFlags return the names of the flags set on a TCP segment
+func TCPFlags(tcp *layers.TCP) []string {
+	flags := []string{}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{{tcp.SYN, "SYN"}, {tcp.FIN, "FIN"}, {tcp.RST, "RST"}, {tcp.PSH, "PSH"}, {tcp.ACK, "ACK"}, {tcp.URG, "URG"}, {tcp.ECE, "ECE"}, {tcp.CWR, "CWR"}} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return flags
+}
+
+//DNSSummary describe the questions and answers of a DNS message
+func DNSSummary(dns *layers.DNS) string {
+	parts := []string{}
+	if dns.QR {
+		parts = append(parts, "response")
+		if dns.ResponseCode != layers.DNSResponseCodeNoErr {
+			parts = append(parts, dns.ResponseCode.String())
+		}
+	} else {
+		parts = append(parts, "query")
+	}
+	parts = append(parts, "0x"+strconv.FormatUint(uint64(dns.ID), 16))
+	for _, q := range dns.Questions {
+		parts = append(parts, q.Type.String(), string(q.Name))
+	}
+	for _, a := range dns.Answers {
+		switch {
+		case a.IP != nil:
+			parts = append(parts, a.Type.String(), a.IP.String())
+		case len(a.CNAME) > 0:
+			parts = append(parts, a.Type.String(), string(a.CNAME))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func endpoint(ip net.IP, port string) string {
+	if port == "" {
+		return ip.String()
+	}
+	return net.JoinHostPort(ip.String(), port)
+}
+
+//Summarize describe a packet of a pod in a single line
+func Summarize(pod string, p gopacket.Packet) Summary {
+	s := Summary{Time: p.Metadata().Timestamp, Pod: pod, Length: p.Metadata().Length}
+	if s.Length == 0 {
+		s.Length = len(p.Data())
+	}
+
+	var src, dst net.IP
+	switch ip := p.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, dst, s.Protocol = ip.SrcIP, ip.DstIP, "IPv4"
+	case *layers.IPv6:
+		src, dst, s.Protocol = ip.SrcIP, ip.DstIP, "IPv6"
+	}
+
+	srcPort, dstPort := "", ""
+	switch t := p.TransportLayer().(type) {
+	case *layers.TCP:
+		srcPort, dstPort, s.Protocol = strconv.Itoa(int(t.SrcPort)), strconv.Itoa(int(t.DstPort)), "TCP"
+		s.Info = fmt.Sprintf("[%s] Seq=%d Ack=%d Win=%d Len=%d", strings.Join(TCPFlags(t), ","), t.Seq, t.Ack, t.Window, len(t.Payload))
+	case *layers.UDP:
+		srcPort, dstPort, s.Protocol = strconv.Itoa(int(t.SrcPort)), strconv.Itoa(int(t.DstPort)), "UDP"
+		s.Info = fmt.Sprintf("Len=%d", len(t.Payload))
+	case *layers.SCTP:
+		srcPort, dstPort, s.Protocol = strconv.Itoa(int(t.SrcPort)), strconv.Itoa(int(t.DstPort)), "SCTP"
+	}
+	if src != nil {
+		s.Src, s.Dst = endpoint(src, srcPort), endpoint(dst, dstPort)
+	}
+
+	// Application layers give a more useful description than the transport
+	if dns, ok := p.Layer(layers.LayerTypeDNS).(*layers.DNS); ok {
+		s.Protocol, s.Info = "DNS", DNSSummary(dns)
+	} else if method, uri, host, ok := HTTPRequest(p); ok {
+		s.Protocol, s.Info = "HTTP", strings.TrimSpace(method+" "+uri+" "+host)
+	} else if icmp, ok := p.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		s.Protocol, s.Info = "ICMP", icmp.TypeCode.String()
+	} else if icmp, ok := p.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); ok {
+		s.Protocol, s.Info = "ICMPv6", icmp.TypeCode.String()
+	} else if arp, ok := p.Layer(layers.LayerTypeARP).(*layers.ARP); ok {
+		s.Protocol = "ARP"
+		s.Src, s.Dst = net.HardwareAddr(arp.SourceHwAddress).String(), net.HardwareAddr(arp.DstHwAddress).String()
+		if arp.Operation == layers.ARPRequest {
+			s.Info = fmt.Sprintf("who has %s? tell %s", net.IP(arp.DstProtAddress), net.IP(arp.SourceProtAddress))
+		} else {
+			s.Info = fmt.Sprintf("%s is at %s", net.IP(arp.SourceProtAddress), net.HardwareAddr(arp.SourceHwAddress))
+		}
+	}
+
+	if s.Protocol == "" {
+		if eth, ok := p.LinkLayer().(*layers.Ethernet); ok {
+			s.Src, s.Dst, s.Protocol = eth.SrcMAC.String(), eth.DstMAC.String(), eth.EthernetType.String()
+		} else if len(p.Layers()) > 0 {
+			s.Protocol = p.Layers()[len(p.Layers())-1].LayerType().String()
+		}
+	}
+	if err := p.ErrorLayer(); err != nil && s.Info == "" {
+		s.Info = err.Error().Error()
+	}
+	return s
+}
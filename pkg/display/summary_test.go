@@ -0,0 +1,141 @@
+package display
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var captured = time.Date(2021, 6, 11, 10, 4, 5, 123456000, time.UTC)
+
+var (
+	webMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	dbMAC  = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+)
+
+//newPacket serialize the layers into an ethernet frame captured at the time of the tests, padded to 60 bytes as on the wire
+func newPacket(t *testing.T, l ...gopacket.SerializableLayer) gopacket.Packet {
+	t.Helper()
+	for _, layer := range l {
+		switch transport := layer.(type) {
+		case *layers.TCP:
+			transport.SetNetworkLayerForChecksum(l[1].(gopacket.NetworkLayer))
+		case *layers.UDP:
+			transport.SetNetworkLayerForChecksum(l[1].(gopacket.NetworkLayer))
+		}
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, l...); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LinkTypeEthernet, gopacket.Default)
+	p.Metadata().CaptureInfo = gopacket.CaptureInfo{Timestamp: captured, CaptureLength: len(buf.Bytes()), Length: len(buf.Bytes())}
+	return p
+}
+
+func ethernet(etherType layers.EthernetType) *layers.Ethernet {
+	return &layers.Ethernet{SrcMAC: webMAC, DstMAC: dbMAC, EthernetType: etherType}
+}
+
+func ipv4(protocol layers.IPProtocol) *layers.IPv4 {
+	return &layers.IPv4{Version: 4, TTL: 64, Protocol: protocol, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+}
+
+func tcpPacket(t *testing.T, tcp *layers.TCP, payload string) gopacket.Packet {
+	return newPacket(t, ethernet(layers.EthernetTypeIPv4), ipv4(layers.IPProtocolTCP), tcp, gopacket.Payload(payload))
+}
+
+func dnsPacket(t *testing.T, dns *layers.DNS) gopacket.Packet {
+	return newPacket(t, ethernet(layers.EthernetTypeIPv4), ipv4(layers.IPProtocolUDP), &layers.UDP{SrcPort: 53, DstPort: 40000}, dns)
+}
+
+var (
+	dnsQuery = &layers.DNS{ID: 0x1a2b, RD: true, Questions: []layers.DNSQuestion{{Name: []byte("db.default.svc"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}}}
+	dnsReply = &layers.DNS{ID: 0x1a2b, QR: true, Questions: dnsQuery.Questions, Answers: []layers.DNSResourceRecord{
+		{Name: []byte("db.default.svc"), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, TTL: 30, CNAME: []byte("db-0.default.svc")},
+		{Name: []byte("db-0.default.svc"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 30, IP: net.IP{10, 0, 0, 2}},
+	}}
+	dnsRefused = &layers.DNS{ID: 7, QR: true, ResponseCode: layers.DNSResponseCodeNXDomain, Questions: dnsQuery.Questions}
+)
+
+func TestHTTPRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		method  string
+		uri     string
+		host    string
+		ok      bool
+	}{
+		{"request", "GET /health HTTP/1.1\r\nHost: web:8080\r\nAccept: */*\r\n\r\n", "GET", "/health", "web:8080", true},
+		{"lower case header", "POST /api HTTP/1.0\r\nhost:api\r\n\r\nbody", "POST", "/api", "api", true},
+		{"host after the headers", "GET / HTTP/1.1\r\n\r\nHost: body", "GET", "/", "", true},
+		{"response", "HTTP/1.1 200 OK\r\n\r\n", "", "", "", false},
+		{"http2", "PRI * HTTP/2.0\r\n\r\n", "", "", "", false},
+		{"not http", "\x16\x03\x01\x02\x00\x01", "", "", "", false},
+		{"no payload", "", "", "", "", false},
+	}
+	for _, tt := range tests {
+		method, uri, host, ok := HTTPRequest(tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 8080, PSH: true, ACK: true}, tt.payload))
+		if method != tt.method || uri != tt.uri || host != tt.host || ok != tt.ok {
+			t.Errorf("%s: HTTPRequest() = %q, %q, %q, %v, want %q, %q, %q, %v", tt.name, method, uri, host, ok, tt.method, tt.uri, tt.host, tt.ok)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	arp := &layers.ARP{AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4, HwAddressSize: 6, ProtAddressSize: 4,
+		Operation: layers.ARPRequest, SourceHwAddress: webMAC, SourceProtAddress: []byte{10, 0, 0, 1}, DstHwAddress: make([]byte, 6), DstProtAddress: []byte{10, 0, 0, 2}}
+	arpReply := *arp
+	arpReply.Operation, arpReply.DstHwAddress = layers.ARPReply, dbMAC
+	ipv6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolTCP, SrcIP: net.ParseIP("fd00::1"), DstIP: net.ParseIP("fd00::2")}
+
+	tests := []struct {
+		name   string
+		packet gopacket.Packet
+		want   Summary
+	}{
+		{"tcp", tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 5432, Seq: 1, SYN: true, Window: 64240}, ""),
+			Summary{Src: "10.0.0.1:40000", Dst: "10.0.0.2:5432", Protocol: "TCP", Length: 60, Info: "[SYN] Seq=1 Ack=0 Win=64240 Len=0"}},
+		{"udp", newPacket(t, ethernet(layers.EthernetTypeIPv4), ipv4(layers.IPProtocolUDP), &layers.UDP{SrcPort: 40000, DstPort: 9000}, gopacket.Payload("ping")),
+			Summary{Src: "10.0.0.1:40000", Dst: "10.0.0.2:9000", Protocol: "UDP", Length: 60, Info: "Len=4"}},
+		{"dns query", dnsPacket(t, dnsQuery),
+			Summary{Src: "10.0.0.1:53", Dst: "10.0.0.2:40000", Protocol: "DNS", Length: 74, Info: "query 0x1a2b A db.default.svc"}},
+		{"dns answer", dnsPacket(t, dnsReply),
+			Summary{Src: "10.0.0.1:53", Dst: "10.0.0.2:40000", Protocol: "DNS", Length: 150, Info: "response 0x1a2b A db.default.svc CNAME db-0.default.svc A 10.0.0.2"}},
+		{"dns error", dnsPacket(t, dnsRefused),
+			Summary{Src: "10.0.0.1:53", Dst: "10.0.0.2:40000", Protocol: "DNS", Length: 74, Info: "response Non-Existent Domain 0x7 A db.default.svc"}},
+		{"http", tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 80, PSH: true, ACK: true}, "GET /index.html HTTP/1.1\r\nHost: web\r\n\r\n"),
+			Summary{Src: "10.0.0.1:40000", Dst: "10.0.0.2:80", Protocol: "HTTP", Length: 93, Info: "GET /index.html web"}},
+		{"icmp", newPacket(t, ethernet(layers.EthernetTypeIPv4), ipv4(layers.IPProtocolICMPv4), &layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0)}),
+			Summary{Src: "10.0.0.1", Dst: "10.0.0.2", Protocol: "ICMP", Length: 60, Info: "EchoRequest"}},
+		{"ipv6", newPacket(t, ethernet(layers.EthernetTypeIPv6), ipv6, &layers.TCP{SrcPort: 40000, DstPort: 443, SYN: true}),
+			Summary{Src: "[fd00::1]:40000", Dst: "[fd00::2]:443", Protocol: "TCP", Length: 74, Info: "[SYN] Seq=0 Ack=0 Win=0 Len=0"}},
+		{"arp request", newPacket(t, ethernet(layers.EthernetTypeARP), arp),
+			Summary{Src: webMAC.String(), Dst: "00:00:00:00:00:00", Protocol: "ARP", Length: 60, Info: "who has 10.0.0.2? tell 10.0.0.1"}},
+		{"arp reply", newPacket(t, ethernet(layers.EthernetTypeARP), &arpReply),
+			Summary{Src: webMAC.String(), Dst: dbMAC.String(), Protocol: "ARP", Length: 60, Info: "10.0.0.1 is at " + webMAC.String()}},
+		{"ethernet only", newPacket(t, ethernet(layers.EthernetTypeLLC), gopacket.Payload{}),
+			Summary{Src: webMAC.String(), Dst: dbMAC.String(), Protocol: "LLC", Length: 60}},
+	}
+	for _, tt := range tests {
+		tt.want.Time, tt.want.Pod = captured, "default/web"
+		if s := Summarize("default/web", tt.packet); s != tt.want {
+			t.Errorf("%s: Summarize() =\n%+v, want\n%+v", tt.name, s, tt.want)
+		}
+	}
+}
+
+func TestSummarizeTruncated(t *testing.T) {
+	p := tcpPacket(t, &layers.TCP{SrcPort: 40000, DstPort: 80, ACK: true}, "")
+	data := p.Data()[:30]
+	truncated := gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+	truncated.Metadata().CaptureInfo = gopacket.CaptureInfo{Timestamp: captured, CaptureLength: len(data), Length: 54}
+	s := Summarize("default/web", truncated)
+	if s.Length != 54 || s.Info == "" {
+		t.Errorf("Summarize() = %+v, want the original length and the decoding error", s)
+	}
+}
@@ -0,0 +1,369 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//The BPF expressions follow the tcpdump syntax, for the most common primitives:
+//	[ether|ip|ip6|arp] [src|dst|src or dst|src and dst] host ADDR
+//	[ip|ip6|arp] [src|dst] net CIDR
+//	[tcp|udp|sctp|ip|ip6] [src|dst] port PORT
+//	[tcp|udp|sctp|ip|ip6] [src|dst] portrange FROM-TO
+//	ip, ip6, arp, tcp, udp, sctp, icmp, icmp6
+//	less LENGTH, greater LENGTH
+//combined with and (&&), or (||), not (!) and parentheses, and and or having the same precedence as in pcap-filter.
+//As in tcpdump, a bare value reuses the qualifiers of the previous primitive: "port 80 or 443".
+//The capture pods compile the capture filter with libpcap, which understands the whole syntax and decides of the packets captured.
+//kpture compiles this subset itself, without cgo nor libpcap on the client, to run the write and display filters
+//and to reject the capture filters libpcap would refuse before they reach the capture pods
+
+//ErrUnsupported is returned for the pcap-filter syntax kpture doesn't compile, such as host names, vlan or tcp[13].
+//The capture pods still accept it
+var ErrUnsupported = errors.New("unsupported by kpture")
+
+//unsupportedKeywords are the pcap-filter primitives and protocols libpcap knows and kpture doesn't compile
+var unsupportedKeywords = map[string]bool{
+	"vlan": true, "mpls": true, "pppoed": true, "pppoes": true, "geneve": true, "gateway": true,
+	"broadcast": true, "multicast": true, "proto": true, "protochain": true, "len": true,
+	"inbound": true, "outbound": true, "ifname": true, "on": true, "rnr": true, "rulenum": true, "reason": true,
+	"rset": true, "ruleset": true, "srnr": true, "subrulenum": true, "action": true,
+	"type": true, "subtype": true, "dir": true, "wlan": true, "fddi": true, "tr": true, "link": true, "ppp": true, "slip": true,
+	"rarp": true, "atalk": true, "aarp": true, "decnet": true, "iso": true, "stp": true, "ipx": true, "netbeui": true,
+	"lat": true, "moprc": true, "mopdl": true, "sca": true, "llc": true, "igmp": true, "igrp": true, "pim": true,
+	"vrrp": true, "carp": true, "ah": true, "esp": true, "radio": true,
+}
+
+//unsupported return true for a token of the pcap-filter syntax kpture doesn't compile:
+//one of unsupportedKeywords, a packet data accessor such as tcp[13], or an arithmetic or comparison operator
+func unsupported(token string) bool {
+	return unsupportedKeywords[token] || strings.ContainsAny(token, "[]<>=&|+*%^") || token == "-"
+}
+
+//primitive is a leaf of a parsed BPF expression
+type primitive struct {
+	proto string
+	dir   string
+	kind  string
+	value string
+}
+
+type node interface{}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ node node }
+
+var (
+	protoKeywords = map[string]bool{"ether": true, "ip": true, "ip6": true, "arp": true, "tcp": true, "udp": true, "sctp": true, "icmp": true, "icmp6": true}
+	kindKeywords  = map[string]bool{"host": true, "net": true, "port": true, "portrange": true}
+	dirKeywords   = map[string]bool{"src": true, "dst": true}
+)
+
+//tokenize split an expression into words, parentheses and operators
+func tokenize(expr string) []string {
+	tokens := []string{}
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			flush()
+		case c == '(' || c == ')':
+			flush()
+			tokens = append(tokens, string(c))
+		case c == '!' && (i+1 >= len(expr) || expr[i+1] != '='):
+			flush()
+			tokens = append(tokens, "not")
+		case (c == '&' || c == '|') && i+1 < len(expr) && expr[i+1] == c:
+			flush()
+			if c == '&' {
+				tokens = append(tokens, "and")
+			} else {
+				tokens = append(tokens, "or")
+			}
+			i++
+		default:
+			word.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	last   *primitive
+}
+
+func (p *parser) peek(offset int) string {
+	if p.pos+offset < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos+offset])
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	t := p.peek(0)
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+//parse build the tree of an expression
+func parse(expr string) (node, error) {
+	p := &parser{tokens: tokenize(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+	n, err := p.parseBinary()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter expression", p.tokens[p.pos])
+	}
+	return n, nil
+}
+
+//parseBinary parse primitives joined by and/or, which have the same precedence in pcap-filter and associate left to right:
+//"tcp or udp and port 53" is "(tcp or udp) and port 53"
+func (p *parser) parseBinary() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(0); op == "and" || op == "or"; op = p.peek(0) {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "and" {
+			left = andNode{left, right}
+		} else {
+			left = orNode{left, right}
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.peek(0) {
+	case "not":
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case "(":
+		p.next()
+		n, err := p.parseBinary()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in filter expression")
+		}
+		return n, nil
+	case "":
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	}
+	return p.parsePrimitive()
+}
+
+func (p *parser) parsePrimitive() (node, error) {
+	if t := p.peek(0); t == "less" || t == "greater" {
+		p.next()
+		value := p.next()
+		if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+			return nil, fmt.Errorf("invalid length %q after %s", value, t)
+		}
+		return primitive{kind: t, value: value}, nil
+	}
+
+	prim := primitive{}
+	qualified := false
+	if protoKeywords[p.peek(0)] {
+		prim.proto, qualified = p.next(), true
+	}
+	if dirKeywords[p.peek(0)] {
+		prim.dir, qualified = p.next(), true
+		// src or dst, src and dst
+		if (p.peek(0) == "or" || p.peek(0) == "and") && dirKeywords[p.peek(1)] && p.peek(1) != prim.dir {
+			prim.dir = "src " + p.next() + " dst"
+			p.next()
+		}
+	}
+	if kindKeywords[p.peek(0)] {
+		prim.kind, qualified = p.next(), true
+	}
+	if t := p.peek(0); unsupported(t) {
+		return nil, fmt.Errorf("%q is %w", p.tokens[p.pos], ErrUnsupported)
+	}
+
+	value := p.peek(0)
+	isValue := value != "" && value != "(" && value != ")" && value != "and" && value != "or" && value != "not" &&
+		!protoKeywords[value] && !dirKeywords[value] && !kindKeywords[value]
+
+	if qualified && prim.kind == "" && isValue {
+		// ether src ADDR, ip dst ADDR
+		prim.kind = "host"
+	}
+
+	switch {
+	case prim.kind != "":
+		if !isValue {
+			return nil, fmt.Errorf("missing value after %s", prim.kind)
+		}
+	case !qualified && isValue:
+		// A bare value reuses the previous qualifiers
+		if p.last == nil {
+			if net.ParseIP(value) == nil {
+				return nil, fmt.Errorf("unexpected %q in filter expression", value)
+			}
+			prim.kind = "host"
+		} else {
+			prim = *p.last
+		}
+	case prim.proto != "" && prim.dir == "":
+		return prim, prim.validate()
+	default:
+		return nil, fmt.Errorf("incomplete filter primitive before %q", value)
+	}
+
+	prim.value = p.tokens[p.pos]
+	p.next()
+	if err := prim.validate(); err != nil {
+		return nil, err
+	}
+	last := prim
+	p.last = &last
+	return prim, nil
+}
+
+//validate check that the qualifiers of the primitive can be combined, and the syntax of its value
+func (prim primitive) validate() error {
+	switch prim.kind {
+	case "":
+		if prim.proto == "ether" {
+			return fmt.Errorf("ether requires a host qualifier")
+		}
+		return nil
+	case "host":
+		if prim.proto == "ether" {
+			if _, err := net.ParseMAC(prim.value); err != nil {
+				return fmt.Errorf("invalid mac address %q", prim.value)
+			}
+			return nil
+		}
+		if prim.proto != "" && prim.proto != "ip" && prim.proto != "ip6" && prim.proto != "arp" {
+			return fmt.Errorf("'%s' modifier applied to host", prim.proto)
+		}
+		ip := net.ParseIP(prim.value)
+		if ip == nil {
+			// Host names are resolved by libpcap
+			return fmt.Errorf("host name %q is %w, only IP addresses are", prim.value, ErrUnsupported)
+		}
+		return checkFamily(prim.proto, ip)
+	case "net":
+		if prim.proto != "" && prim.proto != "ip" && prim.proto != "ip6" && prim.proto != "arp" {
+			return fmt.Errorf("'%s' modifier applied to net", prim.proto)
+		}
+		_, ipnet, err := parseNet(prim.value)
+		if err != nil {
+			return err
+		}
+		return checkFamily(prim.proto, ipnet.IP)
+	case "port", "portrange":
+		if prim.proto != "" && prim.proto != "tcp" && prim.proto != "udp" && prim.proto != "sctp" && prim.proto != "ip" && prim.proto != "ip6" {
+			return fmt.Errorf("'%s' modifier applied to %s", prim.proto, prim.kind)
+		}
+		_, _, err := prim.portRange()
+		return err
+	}
+	return fmt.Errorf("unsupported primitive %s", prim.kind)
+}
+
+func checkFamily(proto string, ip net.IP) error {
+	v4 := ip.To4() != nil
+	if (proto == "ip" || proto == "arp") && !v4 {
+		return fmt.Errorf("%s address %s is not an IPv4 address", proto, ip)
+	}
+	if proto == "ip6" && v4 {
+		return fmt.Errorf("ip6 address %s is not an IPv6 address", ip)
+	}
+	return nil
+}
+
+//parseNet parse a CIDR or a single address
+func parseNet(value string) (net.IP, *net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid net %q", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return ip, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	ip, ipnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid net %q", value)
+	}
+	return ip, ipnet, nil
+}
+
+//portRange return the bounds of a port or portrange primitive
+func (prim primitive) portRange() (uint32, uint32, error) {
+	parsePort := func(s string) (uint32, error) {
+		if n, err := strconv.ParseUint(s, 10, 16); err == nil {
+			return uint32(n), nil
+		}
+		proto := prim.proto
+		if proto != "udp" {
+			proto = "tcp"
+		}
+		n, err := net.LookupPort(proto, s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid port %q", s)
+		}
+		return uint32(n), nil
+	}
+
+	if prim.kind == "port" {
+		port, err := parsePort(prim.value)
+		return port, port, err
+	}
+	bounds := strings.SplitN(prim.value, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid port range %q", prim.value)
+	}
+	from, err := parsePort(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parsePort(bounds[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return from, to, nil
+}
@@ -0,0 +1,262 @@
+package filter
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+//segment describe a tcp or udp packet sent over ethernet
+type segment struct {
+	transport string
+	src, dst  string
+	sport     uint16
+	dport     uint16
+	syn, ack  bool
+	payload   []byte
+}
+
+//packet serialize the segment into an ethernet frame
+func (s segment) packet(t *testing.T) []byte {
+	t.Helper()
+	srcIP, dstIP := net.ParseIP(s.src), net.ParseIP(s.dst)
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}, DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+	var network gopacket.NetworkLayer
+	ipProto := layers.IPProtocolTCP
+	if s.transport == "udp" {
+		ipProto = layers.IPProtocolUDP
+	}
+	ls := []gopacket.SerializableLayer{eth}
+	if srcIP.To4() != nil {
+		ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: ipProto, SrcIP: srcIP.To4(), DstIP: dstIP.To4()}
+		network, ls = ip, append(ls, ip)
+	} else {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: ipProto, SrcIP: srcIP, DstIP: dstIP}
+		network, ls = ip, append(ls, ip)
+	}
+	if s.transport == "udp" {
+		udp := &layers.UDP{SrcPort: layers.UDPPort(s.sport), DstPort: layers.UDPPort(s.dport)}
+		udp.SetNetworkLayerForChecksum(network)
+		ls = append(ls, udp)
+	} else {
+		tcp := &layers.TCP{SrcPort: layers.TCPPort(s.sport), DstPort: layers.TCPPort(s.dport), SYN: s.syn, ACK: s.ack, Window: 1024}
+		tcp.SetNetworkLayerForChecksum(network)
+		ls = append(ls, tcp)
+	}
+	ls = append(ls, gopacket.Payload(s.payload))
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ls...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		expr   string
+		tokens []string
+	}{
+		{"tcp port 80", []string{"tcp", "port", "80"}},
+		{"(tcp||udp)&&!arp", []string{"(", "tcp", "or", "udp", ")", "and", "not", "arp"}},
+		{"  host\t10.0.0.1 ", []string{"host", "10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		tokens := tokenize(tt.expr)
+		if len(tokens) != len(tt.tokens) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.expr, tokens, tt.tokens)
+			continue
+		}
+		for i := range tokens {
+			if tokens[i] != tt.tokens[i] {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.expr, tokens, tt.tokens)
+				break
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tcp := primitive{proto: "tcp"}
+	udp := primitive{proto: "udp"}
+	port53 := primitive{kind: "port", value: "53"}
+	tests := []struct {
+		expr string
+		tree node
+	}{
+		{"tcp", tcp},
+		{"tcp or udp and port 53", andNode{orNode{tcp, udp}, port53}},
+		{"port 53 and tcp or udp", orNode{andNode{port53, tcp}, udp}},
+		{"tcp or (udp and port 53)", orNode{tcp, andNode{udp, port53}}},
+		{"not tcp and udp", andNode{notNode{tcp}, udp}},
+		{"src or dst host 10.0.0.1", primitive{dir: "src or dst", kind: "host", value: "10.0.0.1"}},
+		{"port 80 or 443", orNode{primitive{kind: "port", value: "80"}, primitive{kind: "port", value: "443"}}},
+		{"ip src 10.0.0.1", primitive{proto: "ip", dir: "src", kind: "host", value: "10.0.0.1"}},
+		{"10.0.0.1", primitive{kind: "host", value: "10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		tree, err := parse(tt.expr)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if tree != tt.tree {
+			t.Errorf("parse(%q) = %+v, want %+v", tt.expr, tree, tt.tree)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"tcp and",
+		"(tcp or udp",
+		"tcp)",
+		"port",
+		"port 70000",
+		"portrange 80",
+		"ip host ::1",
+		"ip6 host 10.0.0.1",
+		"tcp host 10.0.0.1",
+		"ether host 10.0.0.1",
+		"ether",
+		"net 10.0.0.0/33",
+		"less many",
+		"tcp prot 80",
+		"src or dst",
+	} {
+		if _, err := parse(expr); err == nil || errors.Is(err, ErrUnsupported) {
+			t.Errorf("parse(%q) = %v, want a syntax error", expr, err)
+		}
+	}
+}
+
+//TestParseUnsupported check that the pcap-filter syntax kpture doesn't compile is told apart from syntax errors
+func TestParseUnsupported(t *testing.T) {
+	for _, expr := range []string{
+		"host db.internal",
+		"vlan and tcp",
+		"tcp and vlan 100",
+		"tcp[tcpflags] & tcp-syn != 0",
+		"ip proto 47",
+		"ether broadcast",
+		"len >= 100",
+		"port 80 and igmp",
+	} {
+		if _, err := parse(expr); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("parse(%q) = %v, want %v", expr, err, ErrUnsupported)
+		}
+	}
+}
+
+func TestCompileBPF(t *testing.T) {
+	web := segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.2", sport: 40000, dport: 80, syn: true}.packet(t)
+	dns := segment{transport: "udp", src: "10.0.0.1", dst: "10.0.0.53", sport: 40000, dport: 53}.packet(t)
+	dnsTCP := segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.53", sport: 40000, dport: 53, syn: true}.packet(t)
+	web6 := segment{transport: "tcp", src: "fd00::1", dst: "fd00::2", sport: 40000, dport: 443, syn: true}.packet(t)
+
+	tests := []struct {
+		expr    string
+		packet  []byte
+		matches bool
+	}{
+		{"tcp", web, true},
+		{"tcp", dns, false},
+		{"udp port 53", dns, true},
+		{"tcp port 53", dns, false},
+		{"tcp or udp and port 53", web, false},
+		{"tcp or udp and port 53", dnsTCP, true},
+		{"tcp or udp and port 53", dns, true},
+		{"tcp or (udp and port 53)", web, true},
+		{"port 53 and tcp or udp", dns, true},
+		{"not udp and port 80", web, true},
+		{"port 80 or 53", dns, true},
+		{"dst port 80", web, true},
+		{"src port 80", web, false},
+		{"portrange 50-100", dns, true},
+		{"portrange 1000-2000", dns, false},
+		{"host 10.0.0.2", web, true},
+		{"src host 10.0.0.2", web, false},
+		{"dst host 10.0.0.2", web, true},
+		{"net 10.0.0.0/24", web, true},
+		{"net 192.168.0.0/16", web, false},
+		{"ip6", web6, true},
+		{"ip6", web, false},
+		{"ip6 host fd00::2", web6, true},
+		{"tcp port https", web6, true},
+		{"ether src 00:01:02:03:04:05", web, true},
+		{"ether dst 00:01:02:03:04:05", web, false},
+		{"greater 1000", web, false},
+		{"less 1000", web, true},
+	}
+	for _, tt := range tests {
+		program, err := CompileBPF(tt.expr, layers.LinkTypeEthernet, 0)
+		if err != nil {
+			t.Errorf("CompileBPF(%q): %v", tt.expr, err)
+			continue
+		}
+		vm, err := bpf.NewVM(program)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		n, err := vm.Run(tt.packet)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if (n > 0) != tt.matches {
+			t.Errorf("%q matches %v, want %v", tt.expr, n > 0, tt.matches)
+		}
+	}
+}
+
+func TestCompileBPFRaw(t *testing.T) {
+	// The raw link type has no ethernet header, the packet starts with the ip header
+	web := segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.2", sport: 40000, dport: 80, syn: true}.packet(t)[14:]
+	tests := []struct {
+		expr    string
+		matches bool
+	}{
+		{"tcp port 80", true},
+		{"udp", false},
+		{"host 10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		program, err := CompileBPF(tt.expr, layers.LinkTypeRaw, 0)
+		if err != nil {
+			t.Errorf("CompileBPF(%q): %v", tt.expr, err)
+			continue
+		}
+		vm, err := bpf.NewVM(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := vm.Run(web); err != nil || (n > 0) != tt.matches {
+			t.Errorf("%q matches %v (%v), want %v", tt.expr, n > 0, err, tt.matches)
+		}
+	}
+	if _, err := CompileBPF("ether host 00:01:02:03:04:05", layers.LinkTypeRaw, 0); err == nil {
+		t.Error("ether host was compiled for the raw link type")
+	}
+}
+
+func TestCompileBPFSnaplen(t *testing.T) {
+	program, err := CompileBPF("tcp", layers.LinkTypeEthernet, 96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := bpf.NewVM(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.2", sport: 40000, dport: 80, syn: true}.packet(t)
+	if n, _ := vm.Run(web); n != 96 {
+		t.Errorf("accepted %d bytes, want 96", n)
+	}
+}
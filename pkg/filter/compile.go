@@ -0,0 +1,416 @@
+package filter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+//maxSnaplen is returned for accepted packets when no snapshot length is given
+const maxSnaplen = 262144
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeARP  = 0x0806
+)
+
+var ipProtocols = map[string]uint32{"icmp": 1, "tcp": 6, "udp": 17, "sctp": 132, "icmp6": 58}
+
+//test is a leaf of the compiled tree: the loads put a value in the accumulator, which is compared to val
+type test struct {
+	loads []bpf.Instruction
+	cond  bpf.JumpTest
+	val   uint32
+}
+
+//constant is a test known at compile time, such as arp on a raw IP link
+type constant bool
+
+//link describe where the network layer starts for a link type
+type link struct {
+	linkType  layers.LinkType
+	l3        uint32
+	etherType int64
+}
+
+func newLink(linkType layers.LinkType) (link, error) {
+	switch linkType {
+	case layers.LinkTypeEthernet:
+		return link{linkType: linkType, l3: 14, etherType: 12}, nil
+	case layers.LinkTypeLinuxSLL:
+		return link{linkType: linkType, l3: 16, etherType: 14}, nil
+	case layers.LinkTypeRaw, layers.LinkTypeIPv4, layers.LinkTypeIPv6:
+		return link{linkType: linkType, etherType: -1}, nil
+	}
+	return link{}, fmt.Errorf("BPF filters are not supported on link type %s", linkType)
+}
+
+//CompileBPF compile a tcpdump-like expression into a BPF program for the link type, accepting snaplen bytes of the matching packets
+func CompileBPF(expr string, linkType layers.LinkType, snaplen uint32) ([]bpf.Instruction, error) {
+	l, err := newLink(linkType)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	tree, err = l.expand(tree)
+	if err != nil {
+		return nil, err
+	}
+	if snaplen == 0 {
+		snaplen = maxSnaplen
+	}
+
+	g := &generator{labels: map[int]int{}}
+	accept, drop := g.label(), g.label()
+	g.emit(tree, accept, drop)
+	g.place(accept)
+	g.add(bpf.RetConstant{Val: snaplen})
+	g.place(drop)
+	g.add(bpf.RetConstant{Val: 0})
+
+	program, err := g.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := bpf.Assemble(program); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+//ethertype test the protocol of the network layer
+func (l link) ethertype(etherType uint32) node {
+	if l.etherType >= 0 {
+		return test{loads: []bpf.Instruction{bpf.LoadAbsolute{Off: uint32(l.etherType), Size: 2}}, cond: bpf.JumpEqual, val: etherType}
+	}
+	// Raw IP links are told apart by the version of the header
+	version := uint32(0)
+	switch etherType {
+	case etherTypeIPv4:
+		version = 0x40
+	case etherTypeIPv6:
+		version = 0x60
+	default:
+		return constant(false)
+	}
+	return test{
+		loads: []bpf.Instruction{bpf.LoadAbsolute{Off: 0, Size: 1}, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xf0}},
+		cond:  bpf.JumpEqual,
+		val:   version,
+	}
+}
+
+func (l link) load(off uint32, size int) []bpf.Instruction {
+	return []bpf.Instruction{bpf.LoadAbsolute{Off: l.l3 + off, Size: size}}
+}
+
+//directions combine the tests of the source and the destination according to the direction qualifier
+func directions(dir string, src, dst node) node {
+	switch dir {
+	case "src":
+		return src
+	case "dst":
+		return dst
+	case "src and dst":
+		return andNode{src, dst}
+	}
+	return orNode{src, dst}
+}
+
+//expand replace the primitives of the tree by tests
+func (l link) expand(n node) (node, error) {
+	switch n := n.(type) {
+	case andNode:
+		left, err := l.expand(n.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := l.expand(n.right)
+		return andNode{left, right}, err
+	case orNode:
+		left, err := l.expand(n.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := l.expand(n.right)
+		return orNode{left, right}, err
+	case notNode:
+		inner, err := l.expand(n.node)
+		return notNode{inner}, err
+	case primitive:
+		return l.primitive(n)
+	}
+	return nil, fmt.Errorf("unexpected filter node %T", n)
+}
+
+func (l link) primitive(prim primitive) (node, error) {
+	switch prim.kind {
+	case "":
+		return l.protocol(prim.proto), nil
+	case "less", "greater":
+		n, _ := strconv.ParseUint(prim.value, 10, 32)
+		cond := bpf.JumpLessOrEqual
+		if prim.kind == "greater" {
+			cond = bpf.JumpGreaterOrEqual
+		}
+		return test{loads: []bpf.Instruction{bpf.LoadExtension{Num: bpf.ExtLen}}, cond: cond, val: uint32(n)}, nil
+	case "host":
+		if prim.proto == "ether" {
+			return l.etherHost(prim)
+		}
+		ip, ipnet, _ := parseNet(prim.value)
+		ipnet.IP = ip
+		return l.network(prim, ipnet), nil
+	case "net":
+		_, ipnet, _ := parseNet(prim.value)
+		return l.network(prim, ipnet), nil
+	case "port", "portrange":
+		return l.ports(prim)
+	}
+	return nil, fmt.Errorf("unsupported primitive %s", prim.kind)
+}
+
+//protocol test a protocol given alone, such as tcp or ip6
+func (l link) protocol(proto string) node {
+	switch proto {
+	case "ip":
+		return l.ethertype(etherTypeIPv4)
+	case "ip6":
+		return l.ethertype(etherTypeIPv6)
+	case "arp":
+		return l.ethertype(etherTypeARP)
+	case "icmp":
+		return l.ipv4Protocol(ipProtocols[proto])
+	case "icmp6":
+		return l.ipv6Protocol(ipProtocols[proto])
+	}
+	return orNode{l.ipv4Protocol(ipProtocols[proto]), l.ipv6Protocol(ipProtocols[proto])}
+}
+
+func (l link) ipv4Protocol(proto uint32) node {
+	return andNode{l.ethertype(etherTypeIPv4), test{loads: l.load(9, 1), cond: bpf.JumpEqual, val: proto}}
+}
+
+//ipv6Protocol only look at the next header of the fixed header, extension headers are not followed
+func (l link) ipv6Protocol(proto uint32) node {
+	return andNode{l.ethertype(etherTypeIPv6), test{loads: l.load(6, 1), cond: bpf.JumpEqual, val: proto}}
+}
+
+func (l link) etherHost(prim primitive) (node, error) {
+	if l.linkType != layers.LinkTypeEthernet {
+		return nil, fmt.Errorf("ether host requires an ethernet link type, not %s", l.linkType)
+	}
+	mac, _ := net.ParseMAC(prim.value)
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid mac address %q", prim.value)
+	}
+	match := func(off uint32) node {
+		return andNode{
+			test{loads: []bpf.Instruction{bpf.LoadAbsolute{Off: off, Size: 4}}, cond: bpf.JumpEqual, val: binary.BigEndian.Uint32(mac)},
+			test{loads: []bpf.Instruction{bpf.LoadAbsolute{Off: off + 4, Size: 2}}, cond: bpf.JumpEqual, val: uint32(binary.BigEndian.Uint16(mac[4:]))},
+		}
+	}
+	return directions(prim.dir, match(6), match(0)), nil
+}
+
+//maskedMatch compare the address at off with a network, one word at a time
+func (l link) maskedMatch(off uint32, ipnet *net.IPNet) node {
+	var n node = constant(true)
+	for i := 0; i < len(ipnet.IP); i += 4 {
+		mask := binary.BigEndian.Uint32(ipnet.Mask[i:])
+		if mask == 0 {
+			continue
+		}
+		loads := l.load(off+uint32(i), 4)
+		if mask != 0xffffffff {
+			loads = append(loads, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: mask})
+		}
+		t := test{loads: loads, cond: bpf.JumpEqual, val: binary.BigEndian.Uint32(ipnet.IP[i:]) & mask}
+		if _, ok := n.(constant); ok {
+			n = t
+		} else {
+			n = andNode{n, t}
+		}
+	}
+	return n
+}
+
+//network match the addresses of IPv4, IPv6 or ARP packets against a host or a network
+func (l link) network(prim primitive, ipnet *net.IPNet) node {
+	if ip4 := ipnet.IP.To4(); ip4 != nil {
+		ipnet = &net.IPNet{IP: ip4.Mask(ipnet.Mask[len(ipnet.Mask)-4:]), Mask: ipnet.Mask[len(ipnet.Mask)-4:]}
+		ip := andNode{l.ethertype(etherTypeIPv4), directions(prim.dir, l.maskedMatch(12, ipnet), l.maskedMatch(16, ipnet))}
+		arp := andNode{l.ethertype(etherTypeARP), directions(prim.dir, l.maskedMatch(14, ipnet), l.maskedMatch(24, ipnet))}
+		switch prim.proto {
+		case "ip":
+			return ip
+		case "arp":
+			return arp
+		}
+		return orNode{ip, arp}
+	}
+	ipnet = &net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask), Mask: ipnet.Mask}
+	return andNode{l.ethertype(etherTypeIPv6), directions(prim.dir, l.maskedMatch(8, ipnet), l.maskedMatch(24, ipnet))}
+}
+
+//ports match the transport ports of TCP, UDP and SCTP packets.
+//IPv4 fragments other than the first one carry no ports and never match
+func (l link) ports(prim primitive) (node, error) {
+	from, to, err := prim.portRange()
+	if err != nil {
+		return nil, err
+	}
+	inRange := func(loads []bpf.Instruction) node {
+		if from == to {
+			return test{loads: loads, cond: bpf.JumpEqual, val: from}
+		}
+		return andNode{
+			test{loads: loads, cond: bpf.JumpGreaterOrEqual, val: from},
+			test{loads: loads, cond: bpf.JumpLessOrEqual, val: to},
+		}
+	}
+
+	protocols := []string{"tcp", "udp", "sctp"}
+	if prim.proto == "tcp" || prim.proto == "udp" || prim.proto == "sctp" {
+		protocols = []string{prim.proto}
+	}
+	transport := func(v4 bool) node {
+		var n node
+		for _, proto := range protocols {
+			off := uint32(9)
+			if !v4 {
+				off = 6
+			}
+			t := test{loads: l.load(off, 1), cond: bpf.JumpEqual, val: ipProtocols[proto]}
+			if n == nil {
+				n = t
+			} else {
+				n = orNode{n, t}
+			}
+		}
+		return n
+	}
+
+	indirect := func(off uint32) []bpf.Instruction {
+		return []bpf.Instruction{bpf.LoadMemShift{Off: l.l3}, bpf.LoadIndirect{Off: l.l3 + off, Size: 2}}
+	}
+	ipv4 := andNode{
+		andNode{l.ethertype(etherTypeIPv4), transport(true)},
+		andNode{
+			notNode{test{loads: l.load(6, 2), cond: bpf.JumpBitsSet, val: 0x1fff}},
+			directions(prim.dir, inRange(indirect(0)), inRange(indirect(2))),
+		},
+	}
+	ipv6 := andNode{
+		andNode{l.ethertype(etherTypeIPv6), transport(false)},
+		directions(prim.dir, inRange(l.load(40, 2)), inRange(l.load(42, 2))),
+	}
+	switch prim.proto {
+	case "ip":
+		return ipv4, nil
+	case "ip6":
+		return ipv6, nil
+	}
+	return orNode{ipv4, ipv6}, nil
+}
+
+//instruction is a BPF instruction whose jumps point to labels until the program is resolved
+type instruction struct {
+	ins         bpf.Instruction
+	jumpTrue    int
+	jumpFalse   int
+	conditional bool
+}
+
+type generator struct {
+	program []instruction
+	labels  map[int]int
+	next    int
+}
+
+func (g *generator) label() int {
+	g.next++
+	return g.next
+}
+
+func (g *generator) place(label int) {
+	g.labels[label] = len(g.program)
+}
+
+func (g *generator) add(ins bpf.Instruction) {
+	g.program = append(g.program, instruction{ins: ins})
+}
+
+//emit generate the code of a tree, jumping to onTrue or onFalse
+func (g *generator) emit(n node, onTrue, onFalse int) {
+	switch n := n.(type) {
+	case andNode:
+		right := g.label()
+		g.emit(n.left, right, onFalse)
+		g.place(right)
+		g.emit(n.right, onTrue, onFalse)
+	case orNode:
+		right := g.label()
+		g.emit(n.left, onTrue, right)
+		g.place(right)
+		g.emit(n.right, onTrue, onFalse)
+	case notNode:
+		g.emit(n.node, onFalse, onTrue)
+	case constant:
+		target := onFalse
+		if n {
+			target = onTrue
+		}
+		g.program = append(g.program, instruction{ins: bpf.Jump{}, jumpTrue: target})
+	case test:
+		for _, load := range n.loads {
+			g.add(load)
+		}
+		g.program = append(g.program, instruction{ins: bpf.JumpIf{Cond: n.cond, Val: n.val}, jumpTrue: onTrue, jumpFalse: onFalse, conditional: true})
+	}
+}
+
+//resolve turn the labels into relative jumps
+func (g *generator) resolve() ([]bpf.Instruction, error) {
+	program := make([]bpf.Instruction, 0, len(g.program))
+	for i, ins := range g.program {
+		skip := func(label int) (int, error) {
+			n := g.labels[label] - i - 1
+			if n < 0 || (ins.conditional && n > 255) {
+				return 0, fmt.Errorf("filter expression is too long")
+			}
+			return n, nil
+		}
+		switch jump := ins.ins.(type) {
+		case bpf.Jump:
+			n, err := skip(ins.jumpTrue)
+			if err != nil {
+				return nil, err
+			}
+			jump.Skip = uint32(n)
+			program = append(program, jump)
+		case bpf.JumpIf:
+			t, err := skip(ins.jumpTrue)
+			if err != nil {
+				return nil, err
+			}
+			f, err := skip(ins.jumpFalse)
+			if err != nil {
+				return nil, err
+			}
+			jump.SkipTrue, jump.SkipFalse = uint8(t), uint8(f)
+			program = append(program, jump)
+		default:
+			program = append(program, ins.ins)
+		}
+	}
+	return program, nil
+}
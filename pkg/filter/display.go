@@ -0,0 +1,445 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/kpture/kpture/pkg/display"
+)
+
+//Display filters are a small subset of the wireshark language, evaluated on the decoded packets:
+//	tcp, udp, dns, http, ...                  protocol is present
+//	ip.addr == 10.0.0.0/8                     address or network, also ip.src, ip.dst, ipv6.addr, ...
+//	tcp.port == 443, udp.dstport >= 1024      ports, compared with == != > >= < <=
+//	dns.qname contains "example"              strings, compared with == != contains matches
+//	http.host == api.local                    host, method and uri of HTTP requests
+//	frame.len > 1000, tcp.flags.syn           packet length and TCP flags, 1 or 0 on the tcp packets
+//combined with and (&&), or (||), not (!) and parentheses.
+
+//predicate is a compiled display filter
+type predicate func(p gopacket.Packet) bool
+
+type fieldKind int
+
+const (
+	kindBool fieldKind = iota
+	kindNumber
+	kindAddress
+	kindString
+)
+
+//field extract the values of a display filter field from a packet
+type field struct {
+	kind   fieldKind
+	values func(p gopacket.Packet) []string
+}
+
+func layerPresent(t gopacket.LayerType) field {
+	return field{kind: kindBool, values: func(p gopacket.Packet) []string {
+		if p.Layer(t) != nil {
+			return []string{"1"}
+		}
+		return nil
+	}}
+}
+
+//tcpFlag is 1 or 0 on the tcp packets, so that tcp.flags.ack == 0 select the tcp packets without the flag
+func tcpFlag(flag func(tcp *layers.TCP) bool) field {
+	return field{kind: kindBool, values: func(p gopacket.Packet) []string {
+		tcp, ok := p.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok {
+			return nil
+		}
+		if flag(tcp) {
+			return []string{"1"}
+		}
+		return []string{"0"}
+	}}
+}
+
+func ipAddresses(v6 bool, src, dst bool) field {
+	return field{kind: kindAddress, values: func(p gopacket.Packet) []string {
+		values := []string{}
+		if v6 {
+			if ip, ok := p.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+				if src {
+					values = append(values, ip.SrcIP.String())
+				}
+				if dst {
+					values = append(values, ip.DstIP.String())
+				}
+			}
+			return values
+		}
+		if ip, ok := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+			if src {
+				values = append(values, ip.SrcIP.String())
+			}
+			if dst {
+				values = append(values, ip.DstIP.String())
+			}
+		}
+		return values
+	}}
+}
+
+func ports(t gopacket.LayerType, src, dst bool) field {
+	return field{kind: kindNumber, values: func(p gopacket.Packet) []string {
+		values := []string{}
+		var srcPort, dstPort int
+		switch l := p.Layer(t).(type) {
+		case *layers.TCP:
+			srcPort, dstPort = int(l.SrcPort), int(l.DstPort)
+		case *layers.UDP:
+			srcPort, dstPort = int(l.SrcPort), int(l.DstPort)
+		case *layers.SCTP:
+			srcPort, dstPort = int(l.SrcPort), int(l.DstPort)
+		default:
+			return nil
+		}
+		if src {
+			values = append(values, strconv.Itoa(srcPort))
+		}
+		if dst {
+			values = append(values, strconv.Itoa(dstPort))
+		}
+		return values
+	}}
+}
+
+func dnsNames(p gopacket.Packet) []string {
+	dns, ok := p.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if !ok {
+		return nil
+	}
+	names := []string{}
+	for _, q := range dns.Questions {
+		names = append(names, string(q.Name))
+	}
+	return names
+}
+
+func httpField(part func(method, uri, host string) string) field {
+	return field{kind: kindString, values: func(p gopacket.Packet) []string {
+		method, uri, host, ok := display.HTTPRequest(p)
+		if !ok {
+			return nil
+		}
+		return []string{part(method, uri, host)}
+	}}
+}
+
+var fields = map[string]field{
+	"eth":    layerPresent(layers.LayerTypeEthernet),
+	"arp":    layerPresent(layers.LayerTypeARP),
+	"ip":     layerPresent(layers.LayerTypeIPv4),
+	"ipv6":   layerPresent(layers.LayerTypeIPv6),
+	"tcp":    layerPresent(layers.LayerTypeTCP),
+	"udp":    layerPresent(layers.LayerTypeUDP),
+	"sctp":   layerPresent(layers.LayerTypeSCTP),
+	"icmp":   layerPresent(layers.LayerTypeICMPv4),
+	"icmpv6": layerPresent(layers.LayerTypeICMPv6),
+	"dns":    layerPresent(layers.LayerTypeDNS),
+	"http": {kind: kindBool, values: func(p gopacket.Packet) []string {
+		if _, _, _, ok := display.HTTPRequest(p); ok {
+			return []string{"1"}
+		}
+		return nil
+	}},
+
+	"frame.len": {kind: kindNumber, values: func(p gopacket.Packet) []string {
+		return []string{strconv.Itoa(len(p.Data()))}
+	}},
+
+	"ip.addr":   ipAddresses(false, true, true),
+	"ip.src":    ipAddresses(false, true, false),
+	"ip.dst":    ipAddresses(false, false, true),
+	"ipv6.addr": ipAddresses(true, true, true),
+	"ipv6.src":  ipAddresses(true, true, false),
+	"ipv6.dst":  ipAddresses(true, false, true),
+
+	"tcp.port":     ports(layers.LayerTypeTCP, true, true),
+	"tcp.srcport":  ports(layers.LayerTypeTCP, true, false),
+	"tcp.dstport":  ports(layers.LayerTypeTCP, false, true),
+	"udp.port":     ports(layers.LayerTypeUDP, true, true),
+	"udp.srcport":  ports(layers.LayerTypeUDP, true, false),
+	"udp.dstport":  ports(layers.LayerTypeUDP, false, true),
+	"sctp.port":    ports(layers.LayerTypeSCTP, true, true),
+	"sctp.srcport": ports(layers.LayerTypeSCTP, true, false),
+	"sctp.dstport": ports(layers.LayerTypeSCTP, false, true),
+
+	"tcp.flags.syn": tcpFlag(func(tcp *layers.TCP) bool { return tcp.SYN }),
+	"tcp.flags.ack": tcpFlag(func(tcp *layers.TCP) bool { return tcp.ACK }),
+	"tcp.flags.fin": tcpFlag(func(tcp *layers.TCP) bool { return tcp.FIN }),
+	"tcp.flags.rst": tcpFlag(func(tcp *layers.TCP) bool { return tcp.RST }),
+	"tcp.flags.psh": tcpFlag(func(tcp *layers.TCP) bool { return tcp.PSH }),
+
+	"dns.qname":    {kind: kindString, values: dnsNames},
+	"dns.qry.name": {kind: kindString, values: dnsNames},
+
+	"http.host":           httpField(func(method, uri, host string) string { return host }),
+	"http.request.method": httpField(func(method, uri, host string) string { return method }),
+	"http.request.uri":    httpField(func(method, uri, host string) string { return uri }),
+}
+
+//displayOperators map the comparison operators to their canonical form
+var displayOperators = map[string]string{
+	"==": "==", "eq": "==", "!=": "!=", "ne": "!=",
+	">": ">", "gt": ">", ">=": ">=", "ge": ">=",
+	"<": "<", "lt": "<", "<=": "<=", "le": "<=",
+	"contains": "contains", "matches": "matches",
+}
+
+//displayToken is a word of a display filter, quoted strings are kept apart from keywords
+type displayToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeDisplay(expr string) ([]displayToken, error) {
+	tokens := []displayToken{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			end := strings.IndexByte(expr[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in display filter")
+			}
+			tokens = append(tokens, displayToken{text: expr[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"),
+			strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="),
+			strings.HasPrefix(expr[i:], ">="), strings.HasPrefix(expr[i:], "<="):
+			tokens = append(tokens, displayToken{text: expr[i : i+2]})
+			i += 2
+		case strings.IndexByte("()!<>", c) >= 0:
+			tokens = append(tokens, displayToken{text: string(c)})
+			i++
+		default:
+			start := i
+			for i < len(expr) && strings.IndexByte(" \t\n\"()!<>=&|", expr[i]) < 0 {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("unexpected %q in display filter", c)
+			}
+			tokens = append(tokens, displayToken{text: expr[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type displayParser struct {
+	tokens []displayToken
+	pos    int
+}
+
+func (p *displayParser) peek() string {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted {
+		return strings.ToLower(p.tokens[p.pos].text)
+	}
+	return ""
+}
+
+//parseDisplay compile a display filter
+func parseDisplay(expr string) (predicate, error) {
+	tokens, err := tokenizeDisplay(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty display filter")
+	}
+	p := &displayParser{tokens: tokens}
+	pred, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in display filter", p.tokens[p.pos].text)
+	}
+	return pred, nil
+}
+
+func (p *displayParser) parseOr() (predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "or" || t == "||"; t = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(pkt gopacket.Packet) bool { return l(pkt) || right(pkt) }
+	}
+	return left, nil
+}
+
+func (p *displayParser) parseAnd() (predicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "and" || t == "&&"; t = p.peek() {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(pkt gopacket.Packet) bool { return l(pkt) && right(pkt) }
+	}
+	return left, nil
+}
+
+func (p *displayParser) parseUnary() (predicate, error) {
+	switch p.peek() {
+	case "not", "!":
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(pkt gopacket.Packet) bool { return !inner(pkt) }, nil
+	case "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in display filter")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *displayParser) parseComparison() (predicate, error) {
+	name := p.peek()
+	if name == "" {
+		return nil, fmt.Errorf("unexpected end of display filter")
+	}
+	f, ok := fields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown display filter field %q", name)
+	}
+	p.pos++
+
+	op, ok := displayOperators[p.peek()]
+	if !ok {
+		// A field alone is true when it is present, and set for the flags
+		return func(pkt gopacket.Packet) bool {
+			for _, v := range f.values(pkt) {
+				if f.kind != kindBool || v != "0" {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	p.pos++
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("missing value after %s %s", name, op)
+	}
+	value := p.tokens[p.pos].text
+	p.pos++
+
+	compare, err := comparison(f.kind, op, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if op == "!=" {
+		// Like wireshark's ~=, != holds when no value is equal
+		return func(pkt gopacket.Packet) bool {
+			values := f.values(pkt)
+			for _, v := range values {
+				if !compare(v) {
+					return false
+				}
+			}
+			return len(values) > 0
+		}, nil
+	}
+	return func(pkt gopacket.Packet) bool {
+		for _, v := range f.values(pkt) {
+			if compare(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+//comparison return a function comparing a value of the field with the value of the filter
+func comparison(kind fieldKind, op, value string) (func(string) bool, error) {
+	switch kind {
+	case kindBool, kindNumber:
+		if op == "contains" || op == "matches" {
+			return nil, fmt.Errorf("%s is not supported on numbers", op)
+		}
+		if kind == kindBool && (value == "true" || value == "false") {
+			value = map[string]string{"true": "1", "false": "0"}[value]
+		}
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		return func(v string) bool {
+			m, _ := strconv.ParseInt(v, 10, 64)
+			switch op {
+			case "==":
+				return m == n
+			case "!=":
+				return m != n
+			case ">":
+				return m > n
+			case ">=":
+				return m >= n
+			case "<":
+				return m < n
+			}
+			return m <= n
+		}, nil
+	case kindAddress:
+		if op != "==" && op != "!=" {
+			return nil, fmt.Errorf("%s is not supported on addresses", op)
+		}
+		_, ipnet, err := parseNet(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(v string) bool {
+			return ipnet.Contains(net.ParseIP(v)) == (op == "==")
+		}, nil
+	}
+
+	switch op {
+	case "==":
+		return func(v string) bool { return strings.EqualFold(v, value) }, nil
+	case "!=":
+		return func(v string) bool { return !strings.EqualFold(v, value) }, nil
+	case "contains":
+		value = strings.ToLower(value)
+		return func(v string) bool { return strings.Contains(strings.ToLower(v), value) }, nil
+	case "matches":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	return nil, fmt.Errorf("%s is not supported on strings", op)
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func dnsQuery(t *testing.T, name string) []byte {
+	t.Helper()
+	buf := gopacket.NewSerializeBuffer()
+	dns := &layers.DNS{ID: 1, RD: true, QDCount: 1, Questions: []layers.DNSQuestion{{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN}}}
+	if err := dns.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDisplayFilter(t *testing.T) {
+	decode := func(b []byte) gopacket.Packet {
+		return gopacket.NewPacket(b, layers.LayerTypeEthernet, gopacket.Default)
+	}
+	syn := decode(segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.2", sport: 40000, dport: 80, syn: true}.packet(t))
+	synAck := decode(segment{transport: "tcp", src: "10.0.0.2", dst: "10.0.0.1", sport: 80, dport: 40000, syn: true, ack: true}.packet(t))
+	request := decode(segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.2", sport: 40000, dport: 80, ack: true,
+		payload: []byte("GET /health HTTP/1.1\r\nHost: api.local\r\n\r\n")}.packet(t))
+	dns := decode(segment{transport: "udp", src: "10.0.0.1", dst: "10.0.0.53", sport: 40000, dport: 53, payload: dnsQuery(t, "db.example.com")}.packet(t))
+	v6 := decode(segment{transport: "tcp", src: "fd00::1", dst: "fd00::2", sport: 40000, dport: 443, ack: true}.packet(t))
+
+	tests := []struct {
+		expr    string
+		packet  gopacket.Packet
+		matches bool
+	}{
+		{"tcp", syn, true},
+		{"tcp", dns, false},
+		{"udp && dns", dns, true},
+		{"tcp.flags.syn", syn, true},
+		{"tcp.flags.syn", request, false},
+		{"tcp.flags.syn == 1 && tcp.flags.ack == 0", syn, true},
+		{"tcp.flags.syn == 1 && tcp.flags.ack == 0", synAck, false},
+		{"tcp.flags.ack == 0", dns, false},
+		{"tcp.flags.ack != 1", syn, true},
+		{"tcp.flags.syn == true", synAck, true},
+		{"not tcp.flags.syn", request, true},
+		{"tcp.port == 80", synAck, true},
+		{"tcp.dstport == 80", synAck, false},
+		{"tcp.port != 80", syn, false},
+		{"udp.dstport >= 53 and udp.dstport <= 53", dns, true},
+		{"ip.addr == 10.0.0.0/24", syn, true},
+		{"ip.src == 10.0.0.2", syn, false},
+		{"ip.dst eq 10.0.0.2", syn, true},
+		{"ipv6.addr == fd00::2", v6, true},
+		{"ip", v6, false},
+		{"frame.len > 1000", syn, false},
+		{"dns.qname == \"db.example.com\"", dns, true},
+		{"dns.qry.name contains example", dns, true},
+		{"dns.qname matches \"^api\"", dns, false},
+		{"http.host == api.local", request, true},
+		{"http.request.method == GET && http.request.uri contains health", request, true},
+		{"http", syn, false},
+		{"tcp.port == 443 or udp and dns", dns, true},
+		{"!(tcp || udp)", dns, false},
+	}
+	for _, tt := range tests {
+		match, err := parseDisplay(tt.expr)
+		if err != nil {
+			t.Errorf("parseDisplay(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := match(tt.packet); got != tt.matches {
+			t.Errorf("%q matches %v, want %v", tt.expr, got, tt.matches)
+		}
+	}
+}
+
+func TestDisplayFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"tcp.window == 0",
+		"tcp.port ==",
+		"tcp.port == http",
+		"tcp.port contains 80",
+		"ip.addr > 10.0.0.1",
+		"dns.qname > a",
+		"dns.qname == \"unterminated",
+		"(tcp",
+		"tcp udp",
+	} {
+		if _, err := parseDisplay(expr); err == nil {
+			t.Errorf("parseDisplay(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestParseFallback(t *testing.T) {
+	tests := []struct {
+		expr    string
+		bpf     bool
+		invalid bool
+	}{
+		{"tcp", false, false},
+		{"tcp.port == 80", false, false},
+		{"tcp port 80", true, false},
+		{"not arp and host 10.0.0.1", true, false},
+		{"tcp.port 80", false, true},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.expr, layers.LinkTypeEthernet)
+		if (err != nil) != tt.invalid {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if err == nil && (f.vm != nil) != tt.bpf {
+			t.Errorf("Parse(%q) compiled to BPF %v, want %v", tt.expr, f.vm != nil, tt.bpf)
+		}
+	}
+
+	f, err := Parse("tcp port 80", layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(segment{transport: "tcp", src: "10.0.0.1", dst: "10.0.0.2", sport: 40000, dport: 80, syn: true}.packet(t), layers.LayerTypeEthernet, gopacket.Default)
+	if !f.Match(p) {
+		t.Error("tcp port 80 doesn't match a packet to port 80")
+	}
+	var none *Filter
+	if !none.Match(p) || none.String() != "" {
+		t.Error("a nil filter doesn't keep every packet")
+	}
+}
@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+//Filter select packets on the client side, with either a display filter or a BPF expression run in userspace.
+//A nil Filter keeps every packet
+type Filter struct {
+	expr    string
+	vm      *bpf.VM
+	display predicate
+}
+
+//Parse compile a filter expression, read as a display filter first and as a BPF expression otherwise.
+//The two languages agree on the protocol names they share, such as "tcp" or "not arp"
+func Parse(expr string, linkType layers.LinkType) (*Filter, error) {
+	display, derr := parseDisplay(expr)
+	if derr == nil {
+		return &Filter{expr: expr, display: display}, nil
+	}
+	program, berr := CompileBPF(expr, linkType, 0)
+	if berr != nil {
+		return nil, fmt.Errorf("%v, or as a BPF expression: %v", derr, berr)
+	}
+	vm, err := bpf.NewVM(program)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{expr: expr, vm: vm}, nil
+}
+
+//For return the filter compiled for the packets of a link type, only BPF expressions depending on the link layer
+func (f *Filter) For(linkType layers.LinkType) (*Filter, error) {
+	if f == nil || f.vm == nil {
+		return f, nil
+	}
+	return Parse(f.expr, linkType)
+}
+
+//Match return true when the packet is selected by the filter
+func (f *Filter) Match(p gopacket.Packet) bool {
+	if f == nil {
+		return true
+	}
+	if f.vm != nil {
+		n, err := f.vm.Run(p.Data())
+		return err == nil && n > 0
+	}
+	return f.display(p)
+}
+
+//String return the expression of the filter
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/kpture/kpture/pkg/socket"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -59,16 +60,30 @@ func InstallDaemonset(Client *kubernetes.Clientset, ns string, containerdns stri
 	return err
 }
 
-func InstallProxy(Client *kubernetes.Clientset, ns string) error {
+//ProxyImage is the default image of the kpture proxy, it serves plain TCP only
+const ProxyImage = "gmtstephane/kpture-proxy:v0.2.0"
+
+//InstallProxy create the deployment of the proxy. With tls, the proxy is given the certificates of the TLS secret,
+//which requires an image serving TLS
+func InstallProxy(Client *kubernetes.Clientset, ns string, image string, tls bool) error {
 	tm := metav1.TypeMeta{APIVersion: "apps/v1"}
 	om := metav1.ObjectMeta{Name: "kpture-proxy"}
 	labelSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "kpture-proxy"}}
-	container := []corev1.Container{{
-		Name: "kpture-proxy", Image: "gmtstephane/kpture-proxy:v0.2.0",
+	container := corev1.Container{
+		Name: "kpture-proxy", Image: image,
 		Env:   []corev1.EnvVar{{Name: "INCLUSTER", Value: "TRUE"}},
 		Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
-	}}
-	podSpec := corev1.PodSpec{Containers: container}
+	}
+	podSpec := corev1.PodSpec{}
+	if tls {
+		// The proxy serves TLS with the certificates of the secret, and requires client certificates signed by its ca
+		container.Env = append(container.Env, corev1.EnvVar{Name: "KPTURE_TLS_DIR", Value: TLSMountPath})
+		container.VolumeMounts = []corev1.VolumeMount{{Name: "tls", MountPath: TLSMountPath, ReadOnly: true}}
+		podSpec.Volumes = []corev1.Volume{
+			{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: socket.TLSSecretName}}},
+		}
+	}
+	podSpec.Containers = []corev1.Container{container}
 	podTemplate := corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "kpture-proxy"}}, Spec: podSpec}
 
 	ds := v1.Deployment{TypeMeta: tm, ObjectMeta: om, Spec: v1.DeploymentSpec{Selector: &labelSelector, Template: podTemplate}}
@@ -102,7 +117,11 @@ func InstallRole(ns string, config *rest.Config) error {
 		fmt.Println(err)
 	}
 
-	cr := rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "kpture-cr", Namespace: ns}, Rules: []rbacv1.PolicyRule{rbacv1.PolicyRule{APIGroups: []string{""}, Verbs: []string{"list", "get"}, Resources: []string{"pods", "nodes"}}}}
+	cr := rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "kpture-cr", Namespace: ns}, Rules: []rbacv1.PolicyRule{
+		rbacv1.PolicyRule{APIGroups: []string{""}, Verbs: []string{"list", "get"}, Resources: []string{"pods", "nodes"}},
+		// The proxy validates the tokens of its clients
+		rbacv1.PolicyRule{APIGroups: []string{"authentication.k8s.io"}, Verbs: []string{"create"}, Resources: []string{"tokenreviews"}},
+	}}
 
 	_, err = client.ClusterRoles().Create(context.Background(), &cr, metav1.CreateOptions{})
 	if err != nil {
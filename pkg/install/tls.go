@@ -0,0 +1,132 @@
+package install
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/kpture/kpture/pkg/socket"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+//TLSMountPath is where the proxy finds the certificates of the TLS secret
+const TLSMountPath = "/etc/kpture/tls"
+
+//CertificateValidity is the lifetime of the certificates created at install, reinstall kpture to renew them
+const CertificateValidity = 365 * 24 * time.Hour
+
+//keyPair is a certificate along with its private key
+type keyPair struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+//newKeyPair create a certificate signed by parent, or self signed when parent is nil
+func newKeyPair(template *x509.Certificate, parent *keyPair) (*keyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(CertificateValidity)
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &keyPair{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+//GenerateTLS create a certificate authority, the certificate of the proxy and a client certificate, keyed as in the TLS secret
+func GenerateTLS(ns string) (map[string][]byte, error) {
+	ca, err := newKeyPair(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "kpture-ca"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	server, err := newKeyPair(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: socket.ServerName},
+		DNSNames:    []string{socket.ServerName, "kpture-proxy-service", "kpture-proxy-service." + ns + ".svc", "localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newKeyPair(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kpture-client"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		socket.TLSCAKey:         ca.certPEM,
+		socket.TLSServerCertKey: server.certPEM,
+		socket.TLSServerKeyKey:  server.keyPEM,
+		socket.TLSClientCertKey: client.certPEM,
+		socket.TLSClientKeyKey:  client.keyPEM,
+	}, nil
+}
+
+//InstallTLSSecret create the secret holding the certificates of the proxy and of its clients, an existing secret is kept
+func InstallTLSSecret(Client *kubernetes.Clientset, ns string) error {
+	data, err := GenerateTLS(ns)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: socket.TLSSecretName, Namespace: ns}, Type: corev1.SecretTypeOpaque, Data: data}
+	_, err = Client.CoreV1().Secrets(ns).Create(context.Background(), secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		fmt.Println("TLS secret already exists, keeping it")
+		return nil
+	}
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println("TLS secret created")
+	return nil
+}
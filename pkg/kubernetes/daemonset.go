@@ -1,76 +1,56 @@
 package kubernetes
 
 import (
-	"bytes"
-	"context"
-	"fmt"
-	"io"
-	"net/url"
+	"errors"
 	"os"
 
 	"github.com/AlecAivazis/survey/v2"
-	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-)
-
-func SelectPod(kubeclient *kubernetes.Clientset, namespace string, kconfig *rest.Config) ([]string, string) {
-
-	pods, err := kubeclient.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		panic(err.Error())
-	}
 
-	listpodString := []string{}
-	listpodselected := []string{}
-
-	for _, pod := range pods.Items {
-		listpodString = append(listpodString, pod.Name)
-	}
-
-	prompt := &survey.MultiSelect{Options: listpodString, PageSize: 30}
-	survey.AskOne(prompt, &listpodselected, survey.WithPageSize(10))
+	"golang.org/x/term"
+)
 
-	service, err := kubeclient.CoreV1().Services("").List(context.Background(), metav1.ListOptions{LabelSelector: "service=kpture-proxy-service"})
-	if err != nil {
-		fmt.Println(err)
-	}
+//SelectPod return the pods to capture.
+//Pods are resolved from the filter when set, otherwise the user is prompted if stdin is a terminal
+func SelectPod(kubeclient *kubernetes.Clientset, namespaces []string, filter PodFilter) ([]v1.Pod, error) {
+	listpodselected := []v1.Pod{}
 
-	u, err := url.Parse(kconfig.Host)
-	if err != nil {
-		panic(err)
-	}
-	return listpodselected, u.Hostname() + ":" + fmt.Sprint(service.Items[0].Spec.Ports[0].NodePort)
-}
+	if filter.IsEmpty() {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.New("no pod specified and stdin is not a terminal, use --pod, --pod-regex, --selector or --field-selector")
+		}
 
-func GetLogs(kubeclient *kubernetes.Clientset, namespace string, pods []string, options v1.PodLogOptions, outputFolder string) error {
+		pods, err := ListPods(kubeclient, namespaces, filter)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, podname := range pods {
+		listpodString := []string{}
+		for _, pod := range pods {
+			if len(namespaces) == 1 {
+				listpodString = append(listpodString, pod.Name)
+			} else {
+				listpodString = append(listpodString, pod.Namespace+"/"+pod.Name)
+			}
+		}
 
-		p, err := kubeclient.CoreV1().Pods(namespace).Get(context.Background(), podname, metav1.GetOptions{})
-		options.Container = p.Spec.Containers[0].Name
-		req := kubeclient.CoreV1().Pods(namespace).GetLogs(podname, &options)
-		podLogs, err := req.Stream(context.Background())
+		selected := []int{}
+		prompt := &survey.MultiSelect{Options: listpodString, PageSize: 30}
+		err = survey.AskOne(prompt, &selected, survey.WithPageSize(10))
 		if err != nil {
-			fmt.Println(err)
-			return err
+			return nil, err
 		}
-		defer podLogs.Close()
-		buf := new(bytes.Buffer)
-		_, err = io.Copy(buf, podLogs)
-		if err != nil {
-			fmt.Println(err)
-			return err
+		for _, i := range selected {
+			listpodselected = append(listpodselected, pods[i])
 		}
-		f, err := os.Create(outputFolder + "/" + podname + "/" + podname + ".log")
+	} else {
+		pods, err := ListPods(kubeclient, namespaces, filter)
 		if err != nil {
-			fmt.Println(err)
-			cobra.CheckErr(err)
+			return nil, err
 		}
-		f.Write(buf.Bytes())
+		listpodselected = pods
 	}
 
-	return nil
+	return listpodselected, nil
 }
@@ -0,0 +1,256 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kpture/kpture/pkg/session"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+//ClusterFilter select the pods and the nodes whose events are recorded
+type ClusterFilter struct {
+	Pod  func(namespace, name string) bool
+	Node func(name string) bool
+}
+
+//WatchCluster run informers on the events, the pods and the nodes of the namespaces, and pass to handle the events about
+//the objects accepted by the filter along with the changes of their status. Events older than since are left out.
+//The resources the user isn't allowed to list and watch are left out as well, and reported.
+//The informers stop when the stop channel is closed
+func WatchCluster(kubeclient *kubernetes.Clientset, namespaces []string, since time.Time, filter ClusterFilter, handle func(session.ClusterEvent), stop <-chan struct{}) {
+	eventNamespaces := append([]string{}, namespaces...)
+	all := false
+	for _, ns := range namespaces {
+		all = all || ns == metav1.NamespaceAll
+	}
+	if !all {
+		// The events of the nodes are recorded in the default namespace
+		eventNamespaces = append(eventNamespaces, metav1.NamespaceDefault)
+	}
+
+	watched := map[string]bool{}
+	for _, ns := range eventNamespaces {
+		if watched[ns] {
+			continue
+		}
+		watched[ns] = true
+		factory := informers.NewSharedInformerFactoryWithOptions(kubeclient, 10*time.Minute, informers.WithNamespace(ns))
+		if canWatch(kubeclient, "events", ns) {
+			onEvent := func(obj interface{}) {
+				if e, ok := obj.(*v1.Event); ok {
+					if event, ok := clusterEvent(e, filter); ok && !event.Time.Before(since) {
+						handle(event)
+					}
+				}
+			}
+			factory.Core().V1().Events().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc: onEvent,
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					// Repeated events are updated with a new count
+					old, ok := oldObj.(*v1.Event)
+					if e, isEvent := newObj.(*v1.Event); ok && isEvent && old.Count != e.Count {
+						onEvent(e)
+					}
+				},
+			})
+		}
+		if contains(namespaces, ns) && canWatch(kubeclient, "pods", ns) {
+			factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					old, ok := oldObj.(*v1.Pod)
+					pod, isPod := newObj.(*v1.Pod)
+					if !ok || !isPod || !filter.Pod(pod.Namespace, pod.Name) {
+						return
+					}
+					for _, event := range podChanges(old, pod, time.Now()) {
+						handle(event)
+					}
+				},
+				DeleteFunc: func(obj interface{}) {
+					if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+						obj = tombstone.Obj
+					}
+					if pod, ok := obj.(*v1.Pod); ok && filter.Pod(pod.Namespace, pod.Name) {
+						handle(session.ClusterEvent{Time: time.Now(), Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Type: session.StateChange, Reason: "Deleted"})
+					}
+				},
+			})
+		}
+		factory.Start(stop)
+	}
+
+	if !canWatch(kubeclient, "nodes", metav1.NamespaceAll) {
+		return
+	}
+	factory := informers.NewSharedInformerFactory(kubeclient, 10*time.Minute)
+	factory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*v1.Node)
+			node, isNode := newObj.(*v1.Node)
+			if !ok || !isNode || !filter.Node(node.Name) {
+				return
+			}
+			for _, event := range nodeChanges(old, node, time.Now()) {
+				handle(event)
+			}
+		},
+	})
+	factory.Start(stop)
+}
+
+//canWatch check with a SelfSubjectAccessReview that the user may list and watch the resource in the namespace,
+//or across the cluster for an empty namespace. Denied or failed checks are reported
+func canWatch(kubeclient *kubernetes.Clientset, resource string, namespace string) bool {
+	scope := "in namespace " + namespace
+	if namespace == metav1.NamespaceAll {
+		scope = "across the cluster"
+	}
+	for _, verb := range []string{"list", "watch"} {
+		review := &authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: verb, Resource: resource},
+		}}
+		review, err := kubeclient.AuthorizationV1().SelfSubjectAccessReviews().Create(context.Background(), review, metav1.CreateOptions{})
+		if err != nil {
+			fmt.Println("Checking access to the", resource, scope+":", err, "- they are not recorded")
+			return false
+		}
+		if !review.Status.Allowed {
+			fmt.Println("Not allowed to", verb, "the", resource, scope+", they are not recorded")
+			return false
+		}
+	}
+	return true
+}
+
+//clusterEvent convert a kubernetes event about a pod or a node accepted by the filter
+func clusterEvent(e *v1.Event, filter ClusterFilter) (session.ClusterEvent, bool) {
+	object := e.InvolvedObject
+	switch object.Kind {
+	case "Pod":
+		if !filter.Pod(object.Namespace, object.Name) {
+			return session.ClusterEvent{}, false
+		}
+	case "Node":
+		if !filter.Node(object.Name) {
+			return session.ClusterEvent{}, false
+		}
+	default:
+		return session.ClusterEvent{}, false
+	}
+
+	event := session.ClusterEvent{Kind: object.Kind, Namespace: object.Namespace, Name: object.Name, Type: e.Type, Reason: e.Reason, Message: e.Message, Count: e.Count}
+	// Events about a container refer to it as spec.containers{name}
+	if i := strings.Index(object.FieldPath, "{"); i >= 0 && strings.HasSuffix(object.FieldPath, "}") {
+		event.Container = object.FieldPath[i+1 : len(object.FieldPath)-1]
+	}
+	switch {
+	case !e.EventTime.IsZero():
+		event.Time = e.EventTime.Time
+	case !e.LastTimestamp.IsZero():
+		event.Time = e.LastTimestamp.Time
+	case !e.FirstTimestamp.IsZero():
+		event.Time = e.FirstTimestamp.Time
+	default:
+		event.Time = e.CreationTimestamp.Time
+	}
+	if e.Series != nil && !e.Series.LastObservedTime.IsZero() {
+		event.Time, event.Count = e.Series.LastObservedTime.Time, e.Series.Count
+	}
+	return event, true
+}
+
+//podChanges return the changes of the phase, the readiness and the container states of a pod
+func podChanges(old *v1.Pod, pod *v1.Pod, now time.Time) []session.ClusterEvent {
+	events := []session.ClusterEvent{}
+	add := func(container string, reason string, message string) {
+		events = append(events, session.ClusterEvent{Time: now, Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Container: container, Type: session.StateChange, Reason: reason, Message: message})
+	}
+
+	if old.Status.Phase != pod.Status.Phase {
+		add("", string(pod.Status.Phase), fmt.Sprintf("phase changed from %s", old.Status.Phase))
+	}
+	if oldReady, ready := podCondition(old, v1.PodReady), podCondition(pod, v1.PodReady); ready != nil && (oldReady == nil || oldReady.Status != ready.Status) {
+		reason := "Ready"
+		if ready.Status != v1.ConditionTrue {
+			reason = "NotReady"
+		}
+		add("", reason, ready.Message)
+	}
+
+	oldStatuses := map[string]v1.ContainerStatus{}
+	for _, status := range append(append([]v1.ContainerStatus{}, old.Status.InitContainerStatuses...), old.Status.ContainerStatuses...) {
+		oldStatuses[status.Name] = status
+	}
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		previous := oldStatuses[status.Name]
+		if status.RestartCount > previous.RestartCount {
+			message := fmt.Sprintf("restart %d", status.RestartCount)
+			if last := status.LastTerminationState.Terminated; last != nil {
+				message = fmt.Sprintf("restart %d after %s, exit code %d", status.RestartCount, last.Reason, last.ExitCode)
+			}
+			add(status.Name, "Restarted", message)
+		}
+		switch state := status.State; {
+		case state.Terminated != nil && previous.State.Terminated == nil:
+			add(status.Name, state.Terminated.Reason, fmt.Sprintf("terminated with exit code %d", state.Terminated.ExitCode))
+		case state.Waiting != nil && state.Waiting.Reason != "" && (previous.State.Waiting == nil || previous.State.Waiting.Reason != state.Waiting.Reason):
+			add(status.Name, state.Waiting.Reason, state.Waiting.Message)
+		}
+		if status.Ready != previous.Ready && (status.Ready || previous.Name != "") {
+			reason := "ContainerReady"
+			if !status.Ready {
+				reason = "ContainerNotReady"
+			}
+			add(status.Name, reason, "")
+		}
+	}
+	return events
+}
+
+func podCondition(pod *v1.Pod, conditionType v1.PodConditionType) *v1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+//nodeChanges return the changes of the conditions of a node, such as Ready or MemoryPressure
+func nodeChanges(old *v1.Node, node *v1.Node, now time.Time) []session.ClusterEvent {
+	events := []session.ClusterEvent{}
+	oldConditions := map[v1.NodeConditionType]v1.ConditionStatus{}
+	for _, condition := range old.Status.Conditions {
+		oldConditions[condition.Type] = condition.Status
+	}
+	for _, condition := range node.Status.Conditions {
+		if previous, ok := oldConditions[condition.Type]; !ok || previous != condition.Status {
+			events = append(events, session.ClusterEvent{Time: now, Kind: "Node", Name: node.Name, Type: session.StateChange, Reason: string(condition.Type), Message: fmt.Sprintf("%s: %s", condition.Status, condition.Message)})
+		}
+	}
+	if old.Spec.Unschedulable != node.Spec.Unschedulable {
+		reason := "Schedulable"
+		if node.Spec.Unschedulable {
+			reason = "Unschedulable"
+		}
+		events = append(events, session.ClusterEvent{Time: now, Kind: "Node", Name: node.Name, Type: session.StateChange, Reason: reason})
+	}
+	return events
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
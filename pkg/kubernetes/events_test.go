@@ -0,0 +1,100 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kpture/kpture/pkg/session"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterEvent(t *testing.T) {
+	filter := ClusterFilter{
+		Pod:  func(namespace, name string) bool { return namespace == "default" && name == "web" },
+		Node: func(name string) bool { return name == "node-1" },
+	}
+	at := time.Date(2021, 6, 11, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		event    v1.Event
+		recorded bool
+		want     session.ClusterEvent
+	}{
+		{"pod", v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "web", FieldPath: "spec.containers{nginx}"},
+			Type:           "Warning", Reason: "Unhealthy", Message: "Readiness probe failed", Count: 3, LastTimestamp: metav1.NewTime(at),
+		}, true, session.ClusterEvent{Time: at, Kind: "Pod", Namespace: "default", Name: "web", Container: "nginx", Type: "Warning", Reason: "Unhealthy", Message: "Readiness probe failed", Count: 3}},
+		{"node", v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "node-1"},
+			Type:           "Normal", Reason: "NodeNotReady", EventTime: metav1.NewMicroTime(at),
+		}, true, session.ClusterEvent{Time: at, Kind: "Node", Name: "node-1", Type: "Normal", Reason: "NodeNotReady"}},
+		{"series", v1.Event{
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "web"},
+			Type:           "Warning", Reason: "BackOff", EventTime: metav1.NewMicroTime(at.Add(-time.Minute)),
+			Series: &v1.EventSeries{Count: 7, LastObservedTime: metav1.NewMicroTime(at)},
+		}, true, session.ClusterEvent{Time: at, Kind: "Pod", Namespace: "default", Name: "web", Type: "Warning", Reason: "BackOff", Count: 7}},
+		{"other pod", v1.Event{InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "db"}}, false, session.ClusterEvent{}},
+		{"other node", v1.Event{InvolvedObject: v1.ObjectReference{Kind: "Node", Name: "node-2"}}, false, session.ClusterEvent{}},
+		{"other kind", v1.Event{InvolvedObject: v1.ObjectReference{Kind: "Deployment", Namespace: "default", Name: "web"}}, false, session.ClusterEvent{}},
+	}
+	for _, tt := range tests {
+		event, recorded := clusterEvent(&tt.event, filter)
+		if recorded != tt.recorded {
+			t.Errorf("%s: recorded %v, want %v", tt.name, recorded, tt.recorded)
+			continue
+		}
+		if recorded && event != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, event, tt.want)
+		}
+	}
+}
+
+func TestPodChanges(t *testing.T) {
+	now := time.Now()
+	old := &v1.Pod{Status: v1.PodStatus{
+		Phase:             v1.PodRunning,
+		Conditions:        []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		ContainerStatuses: []v1.ContainerStatus{{Name: "nginx", Ready: true, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}},
+	}}
+	pod := old.DeepCopy()
+	pod.Status.Conditions[0].Status = v1.ConditionFalse
+	pod.Status.ContainerStatuses[0] = v1.ContainerStatus{
+		Name: "nginx", RestartCount: 1,
+		State:                v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+	}
+
+	reasons := []string{}
+	for _, e := range podChanges(old, pod, now) {
+		if e.Type != session.StateChange || !e.Time.Equal(now) {
+			t.Errorf("unexpected event %+v", e)
+		}
+		reasons = append(reasons, e.Reason+" "+e.Message)
+	}
+	want := []string{"NotReady ", "Restarted restart 1 after OOMKilled, exit code 137", "CrashLoopBackOff ", "ContainerNotReady "}
+	if len(reasons) != len(want) {
+		t.Fatalf("got %q, want %q", reasons, want)
+	}
+	for i := range want {
+		if reasons[i] != want[i] {
+			t.Errorf("got %q, want %q", reasons, want)
+			break
+		}
+	}
+	if changes := podChanges(old, old, now); len(changes) != 0 {
+		t.Errorf("unchanged pod reported %+v", changes)
+	}
+}
+
+func TestNodeChanges(t *testing.T) {
+	old := &v1.Node{Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}, {Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse}}}}
+	node := old.DeepCopy()
+	node.Status.Conditions[1] = v1.NodeCondition{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue, Message: "kubelet has insufficient memory available"}
+	node.Spec.Unschedulable = true
+
+	changes := nodeChanges(old, node, time.Now())
+	if len(changes) != 2 || changes[0].Reason != "MemoryPressure" || changes[0].Message != "True: kubelet has insufficient memory available" || changes[1].Reason != "Unschedulable" {
+		t.Errorf("got %+v", changes)
+	}
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+//NetworkStatusAnnotation describe the interfaces of a pod, it is set by Multus and other CNI meta plugins
+const NetworkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+
+//legacyNetworkStatusAnnotation is the name of the annotation before Multus 3.7
+const legacyNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/networks-status"
+
+//DefaultInterface is the interface of the pod network
+const DefaultInterface = "eth0"
+
+//AllInterfaces select every interface of the pod network status
+const AllInterfaces = "all"
+
+//NetworkInterface is an interface of a pod, as described by its network status
+type NetworkInterface struct {
+	//Network is the name of the network attachment, empty for interfaces missing from the network status
+	Network   string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+	Mac       string   `json:"mac"`
+	Default   bool     `json:"default"`
+}
+
+//PodInterfaces return the interfaces of a pod from its network status, only the default interface being known without it
+func PodInterfaces(pod v1.Pod) ([]NetworkInterface, error) {
+	status, ok := pod.Annotations[NetworkStatusAnnotation]
+	if !ok {
+		status, ok = pod.Annotations[legacyNetworkStatusAnnotation]
+	}
+	if !ok {
+		return []NetworkInterface{{Interface: DefaultInterface, Default: true}}, nil
+	}
+
+	interfaces := []NetworkInterface{}
+	if err := json.Unmarshal([]byte(status), &interfaces); err != nil {
+		return nil, fmt.Errorf("invalid network status of pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	for i := range interfaces {
+		// Older plugins leave out the name of the default interface
+		if interfaces[i].Interface == "" && interfaces[i].Default {
+			interfaces[i].Interface = DefaultInterface
+		}
+	}
+	return interfaces, nil
+}
+
+//SelectInterfaces return the interfaces of the pod matching the names, all selecting every interface of its network status.
+//Names missing from the network status, such as lo, are captured as well
+func SelectInterfaces(pod v1.Pod, names []string) ([]NetworkInterface, error) {
+	known, err := PodInterfaces(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []NetworkInterface{}
+	seen := map[string]bool{}
+	add := func(intf NetworkInterface) {
+		if intf.Interface != "" && !seen[intf.Interface] {
+			seen[intf.Interface] = true
+			selected = append(selected, intf)
+		}
+	}
+	for _, name := range names {
+		if name == AllInterfaces {
+			for _, intf := range known {
+				add(intf)
+			}
+			continue
+		}
+		intf := NetworkInterface{Interface: name}
+		for _, k := range known {
+			if k.Interface == name {
+				intf = k
+			}
+		}
+		add(intf)
+	}
+	return selected, nil
+}
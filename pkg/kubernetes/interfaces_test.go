@@ -0,0 +1,85 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const networkStatus = `[
+	{"name": "cbr0", "ips": ["10.244.1.5"], "default": true},
+	{"name": "default/macvlan", "interface": "net1", "ips": ["192.168.1.5"], "mac": "02:00:00:00:00:01"}
+]`
+
+func podWithStatus(annotation, status string) v1.Pod {
+	pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	if annotation != "" {
+		pod.Annotations = map[string]string{annotation: status}
+	}
+	return pod
+}
+
+func TestPodInterfaces(t *testing.T) {
+	eth0 := NetworkInterface{Network: "cbr0", Interface: "eth0", IPs: []string{"10.244.1.5"}, Default: true}
+	net1 := NetworkInterface{Network: "default/macvlan", Interface: "net1", IPs: []string{"192.168.1.5"}, Mac: "02:00:00:00:00:01"}
+	tests := []struct {
+		name       string
+		pod        v1.Pod
+		interfaces []NetworkInterface
+		valid      bool
+	}{
+		{"no network status", podWithStatus("", ""), []NetworkInterface{{Interface: "eth0", Default: true}}, true},
+		{"network status", podWithStatus(NetworkStatusAnnotation, networkStatus), []NetworkInterface{eth0, net1}, true},
+		{"legacy annotation", podWithStatus(legacyNetworkStatusAnnotation, networkStatus), []NetworkInterface{eth0, net1}, true},
+		{"invalid network status", podWithStatus(NetworkStatusAnnotation, "{"), nil, false},
+	}
+	for _, tt := range tests {
+		interfaces, err := PodInterfaces(tt.pod)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: PodInterfaces() error = %v, want valid %v", tt.name, err, tt.valid)
+			continue
+		}
+		if !reflect.DeepEqual(interfaces, tt.interfaces) {
+			t.Errorf("%s: PodInterfaces() = %+v, want %+v", tt.name, interfaces, tt.interfaces)
+		}
+	}
+}
+
+func TestSelectInterfaces(t *testing.T) {
+	pod := podWithStatus(NetworkStatusAnnotation, networkStatus)
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{[]string{"eth0"}, []string{"eth0"}},
+		{[]string{"net1", "eth0"}, []string{"net1", "eth0"}},
+		{[]string{"all"}, []string{"eth0", "net1"}},
+		{[]string{"all", "lo"}, []string{"eth0", "net1", "lo"}},
+		{[]string{"eth0", "all", "eth0"}, []string{"eth0", "net1"}},
+		{[]string{""}, []string{}},
+	}
+	for _, tt := range tests {
+		selected, err := SelectInterfaces(pod, tt.names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, intf := range selected {
+			names = append(names, intf.Interface)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("SelectInterfaces(%q) = %q, want %q", tt.names, names, tt.want)
+		}
+	}
+
+	// Interfaces missing from the network status are captured without their details
+	selected, _ := SelectInterfaces(pod, []string{"net1", "lo"})
+	if selected[0].Network != "default/macvlan" || selected[0].Mac == "" || !reflect.DeepEqual(selected[1], NetworkInterface{Interface: "lo"}) {
+		t.Errorf("SelectInterfaces() = %+v", selected)
+	}
+	if _, err := SelectInterfaces(podWithStatus(NetworkStatusAnnotation, "{"), []string{"eth0"}); err == nil {
+		t.Error("SelectInterfaces() accepted an invalid network status")
+	}
+}
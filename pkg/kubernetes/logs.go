@@ -0,0 +1,216 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+//logRetryDelay is the time waited before following the logs of a container again, once it started or restarted
+const logRetryDelay = 2 * time.Second
+
+//LogCollector stream the logs of every container of the captured pods to files, from the start of the capture.
+//Each line is written as soon as it is received, prefixed by its timestamp
+type LogCollector struct {
+	client   *kubernetes.Clientset
+	folder   string
+	since    time.Time
+	previous bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	pods   map[string]bool
+}
+
+//NewLogCollector return a collector writing the logs in the folder of each pod, previous also saving the logs
+//of the previous instance of the containers which restarted before the capture
+func NewLogCollector(kubeclient *kubernetes.Clientset, folder string, since time.Time, previous bool) *LogCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LogCollector{client: kubeclient, folder: folder, since: since, previous: previous, ctx: ctx, cancel: cancel, pods: map[string]bool{}}
+}
+
+//Collect follow the logs of every container of the pod, init and ephemeral containers included, unless they are already followed
+func (c *LogCollector) Collect(pod v1.Pod) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := pod.Namespace + "/" + pod.Name
+	if c.pods[key] || c.ctx.Err() != nil {
+		return
+	}
+	c.pods[key] = true
+
+	containers := []string{}
+	for _, container := range pod.Spec.InitContainers {
+		containers = append(containers, container.Name)
+	}
+	for _, container := range pod.Spec.Containers {
+		containers = append(containers, container.Name)
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		containers = append(containers, container.Name)
+	}
+
+	restarted := map[string]bool{}
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		restarted[status.Name] = status.RestartCount > 0
+	}
+
+	for _, container := range containers {
+		c.wg.Add(1)
+		go func(container string) {
+			defer c.wg.Done()
+			if c.previous && restarted[container] {
+				if err := c.savePrevious(pod, container); err != nil {
+					fmt.Println(key, container, "previous logs:", err)
+				}
+			}
+			c.follow(pod, container)
+		}(container)
+	}
+}
+
+//Close stop following the logs and wait for the files to be closed
+func (c *LogCollector) Close() {
+	if c == nil {
+		return
+	}
+	c.cancel()
+	c.wg.Wait()
+}
+
+//path return the log file of a container
+func (c *LogCollector) path(pod v1.Pod, name string) string {
+	return filepath.Join(c.folder, pod.Namespace, pod.Name, name+".log")
+}
+
+//follow write the logs of a container until the collector is closed, the pod is gone or the container terminated for good.
+//The logs are followed again once the container starts or restarts, from the last line written
+func (c *LogCollector) follow(pod v1.Pod, container string) {
+	key := pod.Namespace + "/" + pod.Name
+	f, err := os.OpenFile(c.path(pod, container), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Println(key, container, err)
+		return
+	}
+	defer f.Close()
+
+	since := c.since
+	reported := ""
+	for {
+		options := v1.PodLogOptions{Container: container, Follow: true, Timestamps: true, SinceTime: &metav1.Time{Time: since}}
+		last, err := c.stream(pod, options, f)
+		if last.After(since) {
+			// Lines share the timestamp precision of the runtime, the next ones are strictly later
+			since = last.Add(time.Nanosecond)
+		}
+		if c.ctx.Err() != nil || apierrors.IsNotFound(err) {
+			return
+		}
+		current, gerr := c.client.CoreV1().Pods(pod.Namespace).Get(c.ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(gerr) || (gerr == nil && (current.UID != pod.UID || terminated(current, container))) {
+			return
+		}
+		// Containers waiting to start are reported once
+		if err != nil && err.Error() != reported {
+			reported = err.Error()
+			if !apierrors.IsBadRequest(err) {
+				fmt.Println(key, container, "logs:", err)
+			}
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(logRetryDelay):
+		}
+	}
+}
+
+//terminated return true when the container stopped and won't run again: init containers which completed,
+//ephemeral containers, the containers of a pod which succeeded or failed, and the containers the restart policy doesn't restart
+func terminated(pod *v1.Pod, container string) bool {
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return true
+	}
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		if status.Name == container && status.State.Terminated != nil {
+			return true
+		}
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name == container && status.State.Terminated != nil && status.State.Terminated.ExitCode == 0 {
+			return true
+		}
+	}
+	for _, status := range append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if status.Name != container || status.State.Terminated == nil {
+			continue
+		}
+		switch pod.Spec.RestartPolicy {
+		case v1.RestartPolicyNever:
+			return true
+		case v1.RestartPolicyOnFailure:
+			return status.State.Terminated.ExitCode == 0
+		}
+	}
+	return false
+}
+
+//stream copy the logs matching the options to w line by line, and return the timestamp of the last line
+func (c *LogCollector) stream(pod v1.Pod, options v1.PodLogOptions, w io.Writer) (time.Time, error) {
+	var last time.Time
+	logs, err := c.client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &options).Stream(c.ctx)
+	if err != nil {
+		return last, err
+	}
+	defer logs.Close()
+
+	r := bufio.NewReader(logs)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			if _, werr := io.WriteString(w, line); werr != nil {
+				return last, werr
+			}
+			if i := strings.IndexByte(line, ' '); i > 0 {
+				if t, perr := time.Parse(time.RFC3339Nano, line[:i]); perr == nil {
+					last = t
+				}
+			}
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return last, err
+		}
+	}
+}
+
+//savePrevious write the logs of the previous instance of a container, which restarted before the capture
+func (c *LogCollector) savePrevious(pod v1.Pod, container string) error {
+	f, err := os.Create(c.path(pod, container+"-previous"))
+	if err != nil {
+		return err
+	}
+	_, err = c.stream(pod, v1.PodLogOptions{Container: container, Previous: true, Timestamps: true}, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestTerminated(t *testing.T) {
+	running := v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+	exited := func(code int32) v1.ContainerState {
+		return v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: code}}
+	}
+	pod := func(phase v1.PodPhase, policy v1.RestartPolicy, init, app v1.ContainerState) *v1.Pod {
+		return &v1.Pod{
+			Spec: v1.PodSpec{RestartPolicy: policy},
+			Status: v1.PodStatus{
+				Phase:                 phase,
+				InitContainerStatuses: []v1.ContainerStatus{{Name: "init", State: init}},
+				ContainerStatuses:     []v1.ContainerStatus{{Name: "app", State: app}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		pod        *v1.Pod
+		container  string
+		terminated bool
+	}{
+		{"running", pod(v1.PodRunning, v1.RestartPolicyAlways, exited(0), running), "app", false},
+		{"init container completed", pod(v1.PodRunning, v1.RestartPolicyAlways, exited(0), running), "init", true},
+		{"init container failed, restarted", pod(v1.PodPending, v1.RestartPolicyAlways, exited(1), v1.ContainerState{}), "init", false},
+		{"init container failed, not restarted", pod(v1.PodPending, v1.RestartPolicyNever, exited(1), v1.ContainerState{}), "init", true},
+		{"restarted after exit", pod(v1.PodRunning, v1.RestartPolicyAlways, exited(0), exited(0)), "app", false},
+		{"never restarted", pod(v1.PodRunning, v1.RestartPolicyNever, exited(0), exited(1)), "app", true},
+		{"restarted on failure", pod(v1.PodRunning, v1.RestartPolicyOnFailure, exited(0), exited(1)), "app", false},
+		{"completed, not restarted on success", pod(v1.PodRunning, v1.RestartPolicyOnFailure, exited(0), exited(0)), "app", true},
+		{"pod succeeded", pod(v1.PodSucceeded, v1.RestartPolicyAlways, exited(0), exited(0)), "app", true},
+		{"pod failed", pod(v1.PodFailed, v1.RestartPolicyAlways, exited(0), running), "app", true},
+		{"unknown container", pod(v1.PodRunning, v1.RestartPolicyNever, exited(0), exited(0)), "sidecar", false},
+	}
+	for _, tt := range tests {
+		if got := terminated(tt.pod, tt.container); got != tt.terminated {
+			t.Errorf("%s: terminated(%s) = %v, want %v", tt.name, tt.container, got, tt.terminated)
+		}
+	}
+
+	debug := pod(v1.PodRunning, v1.RestartPolicyAlways, exited(0), running)
+	debug.Status.EphemeralContainerStatuses = []v1.ContainerStatus{{Name: "debugger", State: exited(0)}}
+	if !terminated(debug, "debugger") {
+		t.Error("an ephemeral container which exited is followed")
+	}
+}
@@ -0,0 +1,224 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+//Ways to reach the kpture proxy
+const (
+	//ConnectAuto use the NodePort when it is reachable, and port-forward otherwise
+	ConnectAuto        = "auto"
+	ConnectNodePort    = "nodeport"
+	ConnectPortForward = "port-forward"
+)
+
+//ReachTimeout is the time given to the NodePort to accept a connection before port-forward is used instead
+var ReachTimeout = 3 * time.Second
+
+//forwardRetryDelay is the time waited before reopening a port-forward which ended
+const forwardRetryDelay = 2 * time.Second
+
+//Forwarder forward a local port to the kpture proxy through the API server
+type Forwarder struct {
+	addr   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+//ProxyAddress return the address the captures dial to reach the kpture proxy, along with the port-forward opened to reach it, if any
+func ProxyAddress(kubeclient *kubernetes.Clientset, kconfig *rest.Config, mode string) (string, *Forwarder, error) {
+	switch mode {
+	case ConnectNodePort:
+		addr, err := GetNodeProxyNodePort(kubeclient, kconfig)
+		return addr, nil, err
+	case ConnectPortForward:
+		f, err := PortForward(kubeclient, kconfig)
+		if err != nil {
+			return "", nil, err
+		}
+		return f.Addr(), f, nil
+	case ConnectAuto:
+		addr, err := GetNodeProxyNodePort(kubeclient, kconfig)
+		if err != nil {
+			return "", nil, err
+		}
+		c, err := net.DialTimeout("tcp", addr, ReachTimeout)
+		if err == nil {
+			c.Close()
+			return addr, nil, nil
+		}
+		fmt.Println("The kpture proxy NodePort", addr, "is unreachable, using port-forward:", err)
+		f, err := PortForward(kubeclient, kconfig)
+		if err != nil {
+			return "", nil, err
+		}
+		return f.Addr(), f, nil
+	}
+	return "", nil, fmt.Errorf("unsupported connection mode %q, expected auto, nodeport or port-forward", mode)
+}
+
+//PortForward forward a free local port to a pod of the kpture proxy.
+//When the pod goes away, the same local port is forwarded to another one until the forwarder is closed
+func PortForward(kubeclient *kubernetes.Clientset, kconfig *rest.Config) (*Forwarder, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	local, ended, err := forward(ctx, kubeclient, kconfig, 0)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	f := &Forwarder{addr: fmt.Sprintf("127.0.0.1:%d", local), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ended:
+			}
+			fmt.Println("Port-forward to the kpture proxy ended, reopening it")
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(forwardRetryDelay):
+				}
+				_, next, err := forward(ctx, kubeclient, kconfig, local)
+				if err == nil {
+					ended = next
+					break
+				}
+				fmt.Println("Port-forward to the kpture proxy failed:", err)
+			}
+		}
+	}()
+	return f, nil
+}
+
+//Addr return the local address forwarded to the kpture proxy
+func (f *Forwarder) Addr() string {
+	return f.addr
+}
+
+//Close stop forwarding the port
+func (f *Forwarder) Close() {
+	f.cancel()
+	<-f.done
+}
+
+//forward start forwarding a local port, zero picking a free one, to a running pod of the kpture proxy.
+//It returns the local port once it accepts connections, and a channel closed when the forwarding ends
+func forward(ctx context.Context, kubeclient *kubernetes.Clientset, kconfig *rest.Config, local uint16) (uint16, <-chan struct{}, error) {
+	pod, port, err := proxyPod(kubeclient)
+	if err != nil {
+		return 0, nil, err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(kconfig)
+	if err != nil {
+		return 0, nil, err
+	}
+	req := kubeclient.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+
+	stop, ready := make(chan struct{}), make(chan struct{})
+	pf, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("%d:%d", local, port)}, stop, ready, io.Discard, io.Discard)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ended := make(chan struct{})
+	failed := make(chan error, 1)
+	go func() {
+		defer close(ended)
+		if err := pf.ForwardPorts(); err != nil {
+			failed <- err
+		}
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stop)
+		case <-ended:
+		}
+	}()
+
+	select {
+	case <-ready:
+	case <-ended:
+		select {
+		case err := <-failed:
+			return 0, nil, fmt.Errorf("port-forward to %s/%s: %w", pod.Namespace, pod.Name, err)
+		default:
+			return 0, nil, fmt.Errorf("port-forward to %s/%s ended", pod.Namespace, pod.Name)
+		}
+	}
+	ports, err := pf.GetPorts()
+	if err != nil || len(ports) == 0 {
+		return 0, nil, fmt.Errorf("port-forward to %s/%s has no local port", pod.Namespace, pod.Name)
+	}
+	return ports[0].Local, ended, nil
+}
+
+//proxyPod return a running pod behind the kpture proxy service, and the port it listens on
+func proxyPod(kubeclient *kubernetes.Clientset) (*v1.Pod, int, error) {
+	services, err := kubeclient.CoreV1().Services("").List(context.Background(), metav1.ListOptions{LabelSelector: "service=kpture-proxy-service"})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(services.Items) == 0 || len(services.Items[0].Spec.Ports) == 0 {
+		return nil, 0, errors.New("kpture proxy service not found, make sure kpture is installed on the cluster")
+	}
+	service := services.Items[0]
+
+	pods, err := kubeclient.CoreV1().Pods(service.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String()})
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil {
+			port, err := targetPort(service.Spec.Ports[0], pod)
+			return &pods.Items[i], port, err
+		}
+	}
+	return nil, 0, errors.New("no running kpture proxy pod found")
+}
+
+//targetPort return the container port of the pod a service port forwards to, looking named target ports up in the pod
+func targetPort(port v1.ServicePort, pod v1.Pod) (int, error) {
+	switch {
+	case port.TargetPort.Type == intstr.String:
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = v1.ProtocolTCP
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, p := range container.Ports {
+				if p.Protocol == "" {
+					p.Protocol = v1.ProtocolTCP
+				}
+				if p.Name == port.TargetPort.StrVal && p.Protocol == protocol {
+					return int(p.ContainerPort), nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("port %q of the kpture proxy service not found in pod %s/%s", port.TargetPort.StrVal, pod.Namespace, pod.Name)
+	case port.TargetPort.IntVal != 0:
+		return int(port.TargetPort.IntVal), nil
+	}
+	return int(port.Port), nil
+}
@@ -0,0 +1,34 @@
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestTargetPort(t *testing.T) {
+	pod := v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{
+		{Name: "metrics", Ports: []v1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}},
+		{Name: "kpture-proxy", Ports: []v1.ContainerPort{{Name: "dns", ContainerPort: 5353, Protocol: v1.ProtocolUDP}, {Name: "capture", ContainerPort: 8081, Protocol: v1.ProtocolTCP}}},
+	}}}
+	tests := []struct {
+		name  string
+		port  v1.ServicePort
+		want  int
+		valid bool
+	}{
+		{"number", v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}, 8080, true},
+		{"unset", v1.ServicePort{Port: 8080}, 8080, true},
+		{"name", v1.ServicePort{Port: 80, TargetPort: intstr.FromString("capture")}, 8081, true},
+		{"name of a port without protocol", v1.ServicePort{Port: 80, TargetPort: intstr.FromString("metrics")}, 9090, true},
+		{"name of another protocol", v1.ServicePort{Port: 80, TargetPort: intstr.FromString("dns")}, 0, false},
+		{"unknown name", v1.ServicePort{Port: 80, TargetPort: intstr.FromString("grpc")}, 0, false},
+	}
+	for _, tt := range tests {
+		port, err := targetPort(tt.port, pod)
+		if (err == nil) != tt.valid || port != tt.want {
+			t.Errorf("%s: targetPort() = %d, %v, want %d, valid %v", tt.name, port, err, tt.want, tt.valid)
+		}
+	}
+}
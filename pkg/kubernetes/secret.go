@@ -0,0 +1,30 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kpture/kpture/pkg/socket"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+//LoadTLSSecret return the certificates of the TLS secret created by kpture install next to the proxy, nil when there is none
+func LoadTLSSecret(kubeclient *kubernetes.Clientset) (map[string][]byte, error) {
+	services, err := kubeclient.CoreV1().Services("").List(context.Background(), metav1.ListOptions{LabelSelector: "service=kpture-proxy-service"})
+	if err != nil {
+		return nil, err
+	}
+	if len(services.Items) == 0 {
+		return nil, errors.New("kpture proxy service not found, make sure kpture is installed on the cluster")
+	}
+	secret, err := kubeclient.CoreV1().Secrets(services.Items[0].Namespace).Get(context.Background(), socket.TLSSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
@@ -0,0 +1,117 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+//PodFilter describe the pods to capture without going through the interactive prompt.
+//Names can be given as name or namespace/name
+type PodFilter struct {
+	LabelSelector string
+	FieldSelector string
+	Names         []string
+	NameRegex     string
+}
+
+//IsEmpty return true when no criteria is set on the filter
+func (f PodFilter) IsEmpty() bool {
+	return f.LabelSelector == "" && f.FieldSelector == "" && len(f.Names) == 0 && f.NameRegex == ""
+}
+
+//ListOptions return the options to use for the Pods().List call
+func (f PodFilter) ListOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: f.LabelSelector, FieldSelector: f.FieldSelector}
+}
+
+//Matcher return a function checking a pod against every criteria of the filter
+func (f PodFilter) Matcher() (func(pod v1.Pod) bool, error) {
+	var re *regexp.Regexp
+	if f.NameRegex != "" {
+		var err error
+		re, err = regexp.Compile(f.NameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod regex %q: %w", f.NameRegex, err)
+		}
+	}
+	lselector, err := labels.Parse(f.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", f.LabelSelector, err)
+	}
+	fselector, err := fields.ParseSelector(f.FieldSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field selector %q: %w", f.FieldSelector, err)
+	}
+	names := map[string]bool{}
+	for _, name := range f.Names {
+		names[name] = true
+	}
+
+	return func(pod v1.Pod) bool {
+		if len(names) > 0 && !names[pod.Name] && !names[pod.Namespace+"/"+pod.Name] {
+			return false
+		}
+		if re != nil && !re.MatchString(pod.Name) {
+			return false
+		}
+		return lselector.Matches(labels.Set(pod.Labels)) && fselector.Matches(podFields(pod))
+	}, nil
+}
+
+//podFields return the fields of a pod supported by the api server field selectors
+func podFields(pod v1.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":            pod.Name,
+		"metadata.namespace":       pod.Namespace,
+		"spec.nodeName":            pod.Spec.NodeName,
+		"spec.restartPolicy":       string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":       pod.Spec.SchedulerName,
+		"spec.serviceAccountName":  pod.Spec.ServiceAccountName,
+		"status.phase":             string(pod.Status.Phase),
+		"status.podIP":             pod.Status.PodIP,
+		"status.nominatedNodeName": pod.Status.NominatedNodeName,
+	}
+}
+
+//ListPods return the pods of the namespaces matching the filter, an empty namespace list means all namespaces
+func ListPods(kubeclient *kubernetes.Clientset, namespaces []string, filter PodFilter) ([]v1.Pod, error) {
+	match, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	selected := []v1.Pod{}
+	found := map[string]bool{}
+	for _, namespace := range namespaces {
+		pods, err := kubeclient.CoreV1().Pods(namespace).List(context.Background(), filter.ListOptions())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods.Items {
+			if match(pod) {
+				selected = append(selected, pod)
+				found[pod.Name] = true
+				found[pod.Namespace+"/"+pod.Name] = true
+			}
+		}
+	}
+
+	for _, name := range filter.Names {
+		if !found[name] {
+			return nil, fmt.Errorf("pod %s not found in namespaces %s with the given selectors", name, strings.Join(namespaces, ","))
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var selectorPods = []v1.Pod{
+	{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1", Labels: map[string]string{"app": "web", "tier": "front"}}, Spec: v1.PodSpec{NodeName: "node-1"}, Status: v1.PodStatus{Phase: v1.PodRunning}},
+	{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db-0", Labels: map[string]string{"app": "db"}}, Spec: v1.PodSpec{NodeName: "node-2"}, Status: v1.PodStatus{Phase: v1.PodRunning}},
+	{ObjectMeta: metav1.ObjectMeta{Namespace: "staging", Name: "web-1", Labels: map[string]string{"app": "web"}}, Spec: v1.PodSpec{NodeName: "node-2"}, Status: v1.PodStatus{Phase: v1.PodPending}},
+}
+
+func podNames(pods []v1.Pod) []string {
+	names := []string{}
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  PodFilter
+		matched []string
+		valid   bool
+	}{
+		{"empty", PodFilter{}, []string{"default/web-1", "default/db-0", "staging/web-1"}, true},
+		{"label", PodFilter{LabelSelector: "app=web"}, []string{"default/web-1", "staging/web-1"}, true},
+		{"set based label", PodFilter{LabelSelector: "app in (web,db),tier!=front"}, []string{"default/db-0", "staging/web-1"}, true},
+		{"field", PodFilter{FieldSelector: "spec.nodeName=node-2,status.phase=Running"}, []string{"default/db-0"}, true},
+		{"label and field", PodFilter{LabelSelector: "app=web", FieldSelector: "metadata.namespace!=default"}, []string{"staging/web-1"}, true},
+		{"name", PodFilter{Names: []string{"web-1"}}, []string{"default/web-1", "staging/web-1"}, true},
+		{"namespaced name", PodFilter{Names: []string{"staging/web-1", "db-0"}}, []string{"default/db-0", "staging/web-1"}, true},
+		{"regex", PodFilter{NameRegex: "^db-[0-9]+$"}, []string{"default/db-0"}, true},
+		{"nothing", PodFilter{LabelSelector: "app=cache"}, []string{}, true},
+		{"invalid label", PodFilter{LabelSelector: "app in (web"}, nil, false},
+		{"invalid field", PodFilter{FieldSelector: "spec.nodeName"}, nil, false},
+		{"invalid regex", PodFilter{NameRegex: "web-("}, nil, false},
+	}
+	for _, tt := range tests {
+		match, err := tt.filter.Matcher()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: Matcher() error = %v, want valid %v", tt.name, err, tt.valid)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		matched := []v1.Pod{}
+		for _, pod := range selectorPods {
+			if match(pod) {
+				matched = append(matched, pod)
+			}
+		}
+		if got := podNames(matched); !reflect.DeepEqual(got, tt.matched) {
+			t.Errorf("%s: matched %v, want %v", tt.name, got, tt.matched)
+		}
+	}
+}
+
+//podServer serve the pods of each namespace to a client, leaving the selection to ListPods
+func podServer(t *testing.T) *kubernetes.Clientset {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := v1.PodList{TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"}}
+		for _, pod := range selectorPods {
+			if r.URL.Path == "/api/v1/pods" || r.URL.Path == "/api/v1/namespaces/"+pod.Namespace+"/pods" {
+				list.Items = append(list.Items, pod)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(srv.Close)
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestListPods(t *testing.T) {
+	client := podServer(t)
+	tests := []struct {
+		name       string
+		namespaces []string
+		filter     PodFilter
+		pods       []string
+		err        string
+	}{
+		{"all namespaces", nil, PodFilter{LabelSelector: "app=web"}, []string{"default/web-1", "staging/web-1"}, ""},
+		{"namespace", []string{"staging"}, PodFilter{LabelSelector: "app=web"}, []string{"staging/web-1"}, ""},
+		{"namespaces", []string{"default", "staging"}, PodFilter{FieldSelector: "spec.nodeName=node-2"}, []string{"default/db-0", "staging/web-1"}, ""},
+		{"names", []string{"default"}, PodFilter{Names: []string{"web-1", "default/db-0"}}, []string{"default/web-1", "default/db-0"}, ""},
+		{"missing name", []string{"default"}, PodFilter{Names: []string{"web-1", "cache-0"}}, nil, "pod cache-0 not found"},
+		{"name out of the selector", []string{"staging"}, PodFilter{LabelSelector: "app=db", Names: []string{"web-1"}}, nil, "pod web-1 not found"},
+		{"invalid selector", nil, PodFilter{LabelSelector: "app in (web"}, nil, "invalid selector"},
+	}
+	for _, tt := range tests {
+		pods, err := ListPods(client, tt.namespaces, tt.filter)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: ListPods() error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ListPods() error = %v", tt.name, err)
+			continue
+		}
+		if got := podNames(pods); !reflect.DeepEqual(got, tt.pods) {
+			t.Errorf("%s: ListPods() = %v, want %v", tt.name, got, tt.pods)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -30,6 +31,9 @@ func getNodeProxyNodePort(kubeclient *kubernetes.Clientset) (string, error) {
 		fmt.Println(err)
 		return "", err
 	}
+	if len(service.Items) == 0 || len(service.Items[0].Spec.Ports) == 0 {
+		return "", errors.New("kpture proxy service not found, make sure kpture is installed on the cluster")
+	}
 	return fmt.Sprint(service.Items[0].Spec.Ports[0].NodePort), nil
 }
 
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+//PodHandler is notified of the lifecycle of the watched pods
+type PodHandler interface {
+	//OnRunning is called when a pod is Running, possibly several times for the same pod
+	OnRunning(pod v1.Pod)
+	//OnTerminating is called once the deletion of a running pod is requested
+	OnTerminating(pod v1.Pod)
+	//OnStopped is called when a pod completes, fails or is deleted
+	OnStopped(pod v1.Pod, reason string)
+}
+
+//WatchPods run a pod informer on the namespace and notify the handler for the pods accepted by match.
+//The informer stops when the stop channel is closed
+func WatchPods(kubeclient *kubernetes.Clientset, namespace string, options metav1.ListOptions, match func(pod v1.Pod) bool, handler PodHandler, stop <-chan struct{}) {
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeclient, 10*time.Minute,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = options.LabelSelector
+			o.FieldSelector = options.FieldSelector
+		}),
+	)
+
+	informer := factory.Core().V1().Pods().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok || !match(*pod) {
+				return
+			}
+			if pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil {
+				handler.OnRunning(*pod)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*v1.Pod)
+			if !ok {
+				return
+			}
+			pod, ok := newObj.(*v1.Pod)
+			if !ok || !match(*pod) {
+				return
+			}
+			switch {
+			case pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed:
+				if old.Status.Phase != pod.Status.Phase {
+					handler.OnStopped(*pod, string(pod.Status.Phase))
+				}
+			case pod.DeletionTimestamp != nil:
+				if old.DeletionTimestamp == nil {
+					handler.OnTerminating(*pod)
+				}
+			case pod.Status.Phase == v1.PodRunning:
+				handler.OnRunning(*pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			pod, ok := obj.(*v1.Pod)
+			if !ok || !match(*pod) {
+				return
+			}
+			handler.OnStopped(*pod, "Deleted")
+		},
+	})
+
+	factory.Start(stop)
+}
@@ -0,0 +1,283 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+//Workload represent a kubernetes object owning the pods to capture
+type Workload struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Selector  labels.Selector
+	uid       types.UID
+
+	mu       sync.Mutex
+	replicas map[types.UID]bool
+}
+
+//String return the namespace/kind/name reference of the workload
+func (w *Workload) String() string {
+	return w.Namespace + "/" + w.Kind + "/" + w.Name
+}
+
+var workloadKinds = map[string]string{
+	"pod":          "pod",
+	"pods":         "pod",
+	"po":           "pod",
+	"deployment":   "deployment",
+	"deployments":  "deployment",
+	"deploy":       "deployment",
+	"statefulset":  "statefulset",
+	"statefulsets": "statefulset",
+	"sts":          "statefulset",
+	"daemonset":    "daemonset",
+	"daemonsets":   "daemonset",
+	"ds":           "daemonset",
+	"job":          "job",
+	"jobs":         "job",
+	"service":      "service",
+	"services":     "service",
+	"svc":          "service",
+}
+
+//ParseWorkload parse a [namespace/]kind/name reference such as deployment/foo or backend/svc/bar.
+//The namespace is empty when the reference is not qualified
+func ParseWorkload(ref string) (string, string, string, error) {
+	parts := strings.Split(ref, "/")
+	namespace := ""
+	if len(parts) == 3 {
+		namespace, parts = parts[0], parts[1:]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid target %q, expected [namespace/]kind/name", ref)
+	}
+	kind, ok := workloadKinds[strings.ToLower(parts[0])]
+	if !ok {
+		return "", "", "", fmt.Errorf("unsupported kind %q in %q", parts[0], ref)
+	}
+	return namespace, kind, parts[1], nil
+}
+
+//ResolveWorkload fetch the workload referenced by ref, in the namespace given by the reference
+//or in the default namespace when not qualified
+func ResolveWorkload(kubeclient *kubernetes.Clientset, defaultNamespace string, ref string) (*Workload, error) {
+	namespace, kind, name, err := ParseWorkload(ref)
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	w := &Workload{Kind: kind, Name: name, Namespace: namespace, Selector: labels.Everything()}
+	ctx := context.Background()
+
+	var selector *metav1.LabelSelector
+	switch kind {
+	case "pod":
+		pod, err := kubeclient.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.uid = pod.UID
+		return w, nil
+	case "deployment":
+		obj, err := kubeclient.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.uid, selector = obj.UID, obj.Spec.Selector
+	case "statefulset":
+		obj, err := kubeclient.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.uid, selector = obj.UID, obj.Spec.Selector
+	case "daemonset":
+		obj, err := kubeclient.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.uid, selector = obj.UID, obj.Spec.Selector
+	case "job":
+		obj, err := kubeclient.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.uid, selector = obj.UID, obj.Spec.Selector
+	case "service":
+		obj, err := kubeclient.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.uid = obj.UID
+		if len(obj.Spec.Selector) > 0 {
+			w.Selector = labels.SelectorFromSet(obj.Spec.Selector)
+		}
+		return w, nil
+	}
+
+	if selector != nil {
+		w.Selector, err = metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+//Pods return the running pods currently belonging to the workload
+func (w *Workload) Pods(kubeclient *kubernetes.Clientset) ([]v1.Pod, error) {
+	ctx := context.Background()
+
+	if w.Kind == "pod" {
+		pod, err := kubeclient.CoreV1().Pods(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return runningPods([]v1.Pod{*pod}), nil
+	}
+
+	if w.Kind == "service" {
+		return w.servicePods(kubeclient)
+	}
+
+	owners := map[types.UID]bool{w.uid: true}
+	if w.Kind == "deployment" {
+		// Deployments own their pods through replicasets, which change on every rollout
+		rs, err := kubeclient.AppsV1().ReplicaSets(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.Selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		owners = map[types.UID]bool{}
+		for _, r := range rs.Items {
+			if ref := metav1.GetControllerOf(&r); ref != nil && ref.UID == w.uid {
+				owners[r.UID] = true
+			}
+		}
+	}
+
+	pods, err := kubeclient.CoreV1().Pods(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.Selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	owned := []v1.Pod{}
+	for _, pod := range pods.Items {
+		if ref := metav1.GetControllerOf(&pod); ref != nil && owners[ref.UID] {
+			owned = append(owned, pod)
+		}
+	}
+	return runningPods(owned), nil
+}
+
+//Owns return true when the pod currently belongs to the workload
+func (w *Workload) Owns(kubeclient *kubernetes.Clientset, pod v1.Pod) (bool, error) {
+	// Pods of the other watched namespaces may share the name or the labels of the workload pods
+	if pod.Namespace != w.Namespace {
+		return false, nil
+	}
+	switch w.Kind {
+	case "pod":
+		return pod.Name == w.Name, nil
+	case "service":
+		return !w.Selector.Empty() && w.Selector.Matches(labels.Set(pod.Labels)), nil
+	}
+
+	if !w.Selector.Matches(labels.Set(pod.Labels)) {
+		return false, nil
+	}
+	ref := metav1.GetControllerOf(&pod)
+	if ref == nil {
+		return false, nil
+	}
+	if w.Kind != "deployment" {
+		return ref.UID == w.uid, nil
+	}
+	if ref.Kind != "ReplicaSet" {
+		return false, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.replicas == nil {
+		w.replicas = map[types.UID]bool{}
+	}
+	owned, ok := w.replicas[ref.UID]
+	if !ok {
+		rs, err := kubeclient.AppsV1().ReplicaSets(w.Namespace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		rsref := metav1.GetControllerOf(rs)
+		owned = rsref != nil && rsref.UID == w.uid
+		w.replicas[ref.UID] = owned
+	}
+	return owned, nil
+}
+
+//servicePods return the pods behind the service endpoints, falling back on the service selector
+func (w *Workload) servicePods(kubeclient *kubernetes.Clientset) ([]v1.Pod, error) {
+	ctx := context.Background()
+
+	endpoints, err := kubeclient.CoreV1().Endpoints(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+	if err == nil {
+		names := []string{}
+		for _, subset := range endpoints.Subsets {
+			addresses := []v1.EndpointAddress{}
+			addresses = append(addresses, subset.Addresses...)
+			addresses = append(addresses, subset.NotReadyAddresses...)
+			for _, address := range addresses {
+				if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+					names = append(names, address.TargetRef.Name)
+				}
+			}
+		}
+		if len(names) > 0 {
+			pods, err := ListPods(kubeclient, []string{w.Namespace}, PodFilter{Names: unique(names)})
+			if err != nil {
+				return nil, err
+			}
+			return runningPods(pods), nil
+		}
+	}
+
+	if w.Selector.Empty() {
+		return nil, fmt.Errorf("service %s has no selector nor pod endpoints", w.Name)
+	}
+	pods, err := kubeclient.CoreV1().Pods(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.Selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return runningPods(pods.Items), nil
+}
+
+func runningPods(pods []v1.Pod) []v1.Pod {
+	running := []v1.Pod{}
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodRunning {
+			running = append(running, pod)
+		}
+	}
+	return running
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
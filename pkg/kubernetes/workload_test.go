@@ -0,0 +1,78 @@
+package kubernetes
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		ref       string
+		namespace string
+		kind      string
+		name      string
+		valid     bool
+	}{
+		{"deployment/web", "", "deployment", "web", true},
+		{"deploy/web", "", "deployment", "web", true},
+		{"backend/svc/api", "backend", "service", "api", true},
+		{"STS/db", "", "statefulset", "db", true},
+		{"po/nginx-87ssj", "", "pod", "nginx-87ssj", true},
+		{"ds/agent", "", "daemonset", "agent", true},
+		{"jobs/migrate", "", "job", "migrate", true},
+		{"web", "", "", "", false},
+		{"deployment/", "", "", "", false},
+		{"/web", "", "", "", false},
+		{"replicaset/web", "", "", "", false},
+		{"a/b/c/d", "", "", "", false},
+	}
+	for _, tt := range tests {
+		namespace, kind, name, err := ParseWorkload(tt.ref)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseWorkload(%q) error = %v, want valid %v", tt.ref, err, tt.valid)
+			continue
+		}
+		if namespace != tt.namespace || kind != tt.kind || name != tt.name {
+			t.Errorf("ParseWorkload(%q) = %q, %q, %q, want %q, %q, %q", tt.ref, namespace, kind, name, tt.namespace, tt.kind, tt.name)
+		}
+	}
+}
+
+func TestOwns(t *testing.T) {
+	controller := true
+	selector := labels.SelectorFromSet(labels.Set{"app": "web"})
+	pod := func(namespace, name string, owner types.UID) v1.Pod {
+		p := v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": "web"}}}
+		if owner != "" {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "StatefulSet", Name: "web", UID: owner, Controller: &controller}}
+		}
+		return p
+	}
+	tests := []struct {
+		name     string
+		workload *Workload
+		pod      v1.Pod
+		owned    bool
+	}{
+		{"pod", &Workload{Kind: "pod", Name: "web-0", Namespace: "default"}, pod("default", "web-0", ""), true},
+		{"pod of another namespace", &Workload{Kind: "pod", Name: "web-0", Namespace: "default"}, pod("staging", "web-0", ""), false},
+		{"other pod", &Workload{Kind: "pod", Name: "web-0", Namespace: "default"}, pod("default", "web-1", ""), false},
+		{"service", &Workload{Kind: "service", Name: "web", Namespace: "default", Selector: selector}, pod("default", "web-0", ""), true},
+		{"service pod of another namespace", &Workload{Kind: "service", Name: "web", Namespace: "default", Selector: selector}, pod("staging", "web-0", ""), false},
+		{"service without selector", &Workload{Kind: "service", Name: "web", Namespace: "default", Selector: labels.Everything()}, pod("default", "web-0", ""), false},
+		{"statefulset", &Workload{Kind: "statefulset", Name: "web", Namespace: "default", Selector: selector, uid: "sts"}, pod("default", "web-0", "sts"), true},
+		{"statefulset pod of another namespace", &Workload{Kind: "statefulset", Name: "web", Namespace: "default", Selector: selector, uid: "sts"}, pod("staging", "web-0", "sts"), false},
+		{"pod of another statefulset", &Workload{Kind: "statefulset", Name: "web", Namespace: "default", Selector: selector, uid: "sts"}, pod("default", "web-0", "other"), false},
+		{"deployment pod of another namespace", &Workload{Kind: "deployment", Name: "web", Namespace: "default", Selector: selector, uid: "deploy"}, pod("staging", "web-0", "rs"), false},
+	}
+	for _, tt := range tests {
+		owned, err := tt.workload.Owns(nil, tt.pod)
+		if err != nil || owned != tt.owned {
+			t.Errorf("%s: Owns() = %v, %v, want %v", tt.name, owned, err, tt.owned)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package merge
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/kpture/kpture/pkg/pcapfile"
+)
+
+var pcapngMagic = []byte{0x0A, 0x0D, 0x0D, 0x0A}
+
+//reader read the packets of a pcap or pcapng file
+type reader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+}
+
+//input is a file being merged, along with its next packet
+type input struct {
+	path       string
+	f          *os.File
+	r          reader
+	ng         *pcapgo.NgReader
+	linkType   layers.LinkType
+	snaplen    uint32
+	interfaces map[int]int
+	data       []byte
+	ci         gopacket.CaptureInfo
+}
+
+func openInput(path string) (*input, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	in := &input{path: path, f: f, interfaces: map[int]int{}}
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(4)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if bytes.Equal(magic, pcapngMagic) {
+		// Interfaces may have different link types, their packets are all kept
+		ng, err := pcapgo.NewNgReader(br, pcapgo.NgReaderOptions{WantMixedLinkType: true, SkipUnknownVersion: true})
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		in.r, in.ng, in.linkType = ng, ng, ng.LinkType()
+		if intf, err := ng.Interface(0); err == nil {
+			in.snaplen = intf.SnapLength
+		}
+		return in, nil
+	}
+	r, err := pcapgo.NewReader(br)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	in.r, in.linkType, in.snaplen = r, r.LinkType(), r.Snaplen()
+	return in, nil
+}
+
+//next read the next packet of the input, returning false at the end of the file
+func (in *input) next() (bool, error) {
+	data, ci, err := in.r.ReadPacketData()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// A capture interrupted in the middle of a packet still merges its complete packets
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", in.path, err)
+	}
+	in.data, in.ci = data, ci
+	return true, nil
+}
+
+//interfaceOf return the interface of the output receiving the packets of an interface of the input
+func (in *input) interfaceOf(out *pcapfile.File, index int) (int, error) {
+	if i, ok := in.interfaces[index]; ok {
+		return i, nil
+	}
+	intf := pcapfile.Interface{Name: strings.TrimSuffix(filepath.Base(in.path), filepath.Ext(in.path)), LinkType: in.linkType, Snaplen: in.snaplen}
+	if in.ng != nil {
+		if ngIntf, err := in.ng.Interface(index); err == nil {
+			intf.LinkType, intf.Snaplen, intf.Comment = ngIntf.LinkType, ngIntf.SnapLength, ngIntf.Comment
+			if ngIntf.Name != "" {
+				intf.Name = ngIntf.Name
+			}
+			intf.Description = ngIntf.Description
+		}
+	}
+	i, err := out.AddInterface(intf)
+	if err != nil {
+		return 0, err
+	}
+	in.interfaces[index] = i
+	return i, nil
+}
+
+//inputs is a heap of inputs ordered by the timestamp of their next packet
+type inputs []*input
+
+func (h inputs) Len() int            { return len(h) }
+func (h inputs) Less(i, j int) bool  { return h[i].ci.Timestamp.Before(h[j].ci.Timestamp) }
+func (h inputs) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *inputs) Push(x interface{}) { *h = append(*h, x.(*input)) }
+func (h *inputs) Pop() interface{} {
+	old := *h
+	in := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return in
+}
+
+//Files merge capture files into output in the order of the packet timestamps.
+//The output is written as pcapng, with an interface per input, when the format asks for it or when the link types differ
+func Files(output string, paths []string, opts pcapfile.Options) (uint64, error) {
+	opened := []*input{}
+	defer func() {
+		for _, in := range opened {
+			in.f.Close()
+		}
+	}()
+	for _, path := range paths {
+		in, err := openInput(path)
+		if err != nil {
+			return 0, err
+		}
+		opened = append(opened, in)
+		if opts.LinkType == 0 {
+			opts.LinkType = in.linkType
+		}
+		if in.linkType != opts.LinkType {
+			opts.Format = pcapfile.FormatPcapng
+		}
+		if in.snaplen > opts.Snaplen {
+			opts.Snaplen = in.snaplen
+		}
+	}
+
+	out, err := pcapfile.Create(output, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	h := &inputs{}
+	for _, in := range opened {
+		ok, err := in.next()
+		if err != nil {
+			out.Close()
+			return 0, err
+		}
+		if ok {
+			heap.Push(h, in)
+		}
+	}
+
+	var packets uint64
+	for h.Len() > 0 {
+		in := (*h)[0]
+		index, err := in.interfaceOf(out, in.ci.InterfaceIndex)
+		if err != nil {
+			out.Close()
+			return packets, err
+		}
+		ci := in.ci
+		ci.InterfaceIndex = index
+		if err := out.WritePacket(ci, in.data); err != nil {
+			out.Close()
+			return packets, err
+		}
+		packets++
+
+		ok, err := in.next()
+		if err != nil {
+			out.Close()
+			return packets, err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return packets, out.Close()
+}
@@ -0,0 +1,123 @@
+package merge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/kpture/kpture/pkg/pcapfile"
+)
+
+var epoch = time.Unix(1600000000, 0)
+
+//writePcap write a pcap file holding a packet at each millisecond offset, the first byte of the packet being the offset
+func writePcap(t *testing.T, path string, linkType layers.LinkType, offsets ...int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := pcapgo.NewWriter(f)
+	if err := w.WriteFileHeader(1500, linkType); err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range offsets {
+		data := make([]byte, 14)
+		data[0] = byte(offset)
+		ci := gopacket.CaptureInfo{Timestamp: epoch.Add(time.Duration(offset) * time.Millisecond), CaptureLength: len(data), Length: len(data)}
+		if err := w.WritePacket(ci, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//readOffsets return the offsets of the packets of a merged file, and whether it was written as pcapng
+func readOffsets(t *testing.T, path string) ([]int, bool) {
+	t.Helper()
+	in, err := openInput(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.f.Close()
+	offsets := []int{}
+	for {
+		ok, err := in.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return offsets, in.ng != nil
+		}
+		offsets = append(offsets, int(in.data[0]))
+	}
+}
+
+func TestFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs map[string][]int
+		sll    string
+		// truncate cut the last bytes of an input, as an interrupted capture would
+		truncate string
+		offsets  []int
+		pcapng   bool
+	}{
+		{"interleaved", map[string][]int{"web": {0, 20, 40}, "db": {10, 30}, "idle": {}}, "", "", []int{0, 10, 20, 30, 40}, false},
+		{"equal timestamps", map[string][]int{"web": {5, 5}, "db": {5}}, "", "", []int{5, 5, 5}, false},
+		{"mixed link types", map[string][]int{"web": {3, 7}, "db": {1, 5}}, "db", "", []int{1, 3, 5, 7}, true},
+		{"truncated input", map[string][]int{"web": {2, 4, 6}, "db": {1, 3}}, "", "web", []int{1, 2, 3, 4}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			paths := []string{}
+			for name, offsets := range tt.inputs {
+				path := filepath.Join(dir, name+".pcap")
+				linkType := layers.LinkTypeEthernet
+				if name == tt.sll {
+					linkType = layers.LinkTypeLinuxSLL
+				}
+				writePcap(t, path, linkType, offsets...)
+				if name == tt.truncate {
+					info, err := os.Stat(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := os.Truncate(path, info.Size()-4); err != nil {
+						t.Fatal(err)
+					}
+				}
+				paths = append(paths, path)
+			}
+
+			output := filepath.Join(dir, "merged")
+			packets, err := Files(output, paths, pcapfile.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if packets != uint64(len(tt.offsets)) {
+				t.Errorf("Files() = %d packets, want %d", packets, len(tt.offsets))
+			}
+			offsets, pcapng := readOffsets(t, output)
+			if pcapng != tt.pcapng {
+				t.Errorf("pcapng output %v, want %v", pcapng, tt.pcapng)
+			}
+			if len(offsets) != len(tt.offsets) {
+				t.Fatalf("merged %v, want %v", offsets, tt.offsets)
+			}
+			for i := range offsets {
+				if offsets[i] != tt.offsets[i] {
+					t.Fatalf("merged %v, want %v", offsets, tt.offsets)
+				}
+			}
+		})
+	}
+}
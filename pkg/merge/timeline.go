@@ -0,0 +1,341 @@
+package merge
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/kpture/kpture/pkg/display"
+	"github.com/kpture/kpture/pkg/filter"
+	"github.com/kpture/kpture/pkg/session"
+)
+
+//Entry is a packet or a container log line of a session
+type Entry struct {
+	Time   time.Time
+	Source display.Source
+	//Container and Line are set for log lines
+	Container string
+	Line      string
+	//Packet is set for packets
+	Packet gopacket.Packet
+}
+
+//TimelineOptions select the entries of a timeline, zero values keeping every entry
+type TimelineOptions struct {
+	//Pods are pod names or namespace/pod
+	Pods []string
+	//Containers select the log lines, packets are not bound to a container
+	Containers []string
+	//Filter select the packets, log lines are kept
+	Filter *filter.Filter
+	Since  time.Time
+	Until  time.Time
+}
+
+//source is a pod file of the session, along with its next entry
+type source struct {
+	f     *os.File
+	next  func() (bool, error)
+	entry Entry
+}
+
+//sources is a heap of sources ordered by the timestamp of their next entry
+type sources []*source
+
+func (h sources) Len() int            { return len(h) }
+func (h sources) Less(i, j int) bool  { return h[i].entry.Time.Before(h[j].entry.Time) }
+func (h sources) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sources) Push(x interface{}) { *h = append(*h, x.(*source)) }
+func (h *sources) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+//Timeline read the packets and the container logs of the pods of a session folder in timestamp order
+type Timeline struct {
+	opts    TimelineOptions
+	opened  []*source
+	pending sources
+	started bool
+}
+
+//OpenTimeline open the pod files of a session folder, the packets of folder/namespace/pod/*.pcap(ng)
+//and the log lines of folder/namespace/pod/*.log. The merged and workload files are left out, they repeat the pod packets
+func OpenTimeline(folder string, opts TimelineOptions) (*Timeline, error) {
+	paths, err := filepath.Glob(filepath.Join(folder, "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	interfaces := fileInterfaces(folder)
+
+	t := &Timeline{opts: opts}
+	for _, path := range paths {
+		pod, namespace := filepath.Base(filepath.Dir(path)), filepath.Base(filepath.Dir(filepath.Dir(path)))
+		src := display.Source{Namespace: namespace, Pod: pod}
+		if !t.selectPod(src) {
+			continue
+		}
+		var s *source
+		switch filepath.Ext(path) {
+		case ".pcap", ".pcapng":
+			src.Interface = interfaceOf(interfaces, path)
+			s, err = t.packets(path, src)
+		case ".log":
+			container := strings.TrimSuffix(filepath.Base(path), ".log")
+			if !t.selectContainer(container) {
+				continue
+			}
+			s, err = t.logs(path, src, container)
+		default:
+			continue
+		}
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.opened = append(t.opened, s)
+	}
+	return t, nil
+}
+
+//Next return the next entry of the timeline, io.EOF once every file is read
+func (t *Timeline) Next() (Entry, error) {
+	if !t.started {
+		t.started = true
+		for _, s := range t.opened {
+			if err := t.push(s); err != nil {
+				return Entry{}, err
+			}
+		}
+	}
+	if t.pending.Len() == 0 {
+		return Entry{}, io.EOF
+	}
+	s := t.pending[0]
+	entry := s.entry
+	ok, err := t.advance(s)
+	if err != nil {
+		return Entry{}, err
+	}
+	if ok {
+		heap.Fix(&t.pending, 0)
+	} else {
+		heap.Pop(&t.pending)
+	}
+	return entry, nil
+}
+
+//Close close the files of the timeline
+func (t *Timeline) Close() error {
+	var err error
+	for _, s := range t.opened {
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+//push add a source to the pending ones unless it has no entry in the time window
+func (t *Timeline) push(s *source) error {
+	ok, err := t.advance(s)
+	if ok {
+		heap.Push(&t.pending, s)
+	}
+	return err
+}
+
+//advance read the next entry of a source within the time window, returning false at the end of the source
+func (t *Timeline) advance(s *source) (bool, error) {
+	for {
+		ok, err := s.next()
+		if err != nil || !ok {
+			return false, err
+		}
+		if !t.opts.Since.IsZero() && s.entry.Time.Before(t.opts.Since) {
+			continue
+		}
+		if !t.opts.Until.IsZero() && s.entry.Time.After(t.opts.Until) {
+			continue
+		}
+		return true, nil
+	}
+}
+
+func (t *Timeline) selectPod(src display.Source) bool {
+	if len(t.opts.Pods) == 0 {
+		return true
+	}
+	for _, pod := range t.opts.Pods {
+		if pod == src.Pod || pod == src.Namespace+"/"+src.Pod {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Timeline) selectContainer(container string) bool {
+	if len(t.opts.Containers) == 0 {
+		return true
+	}
+	for _, c := range t.opts.Containers {
+		// The logs of the previous instance of a container belong to it
+		if c == container || c+"-previous" == container {
+			return true
+		}
+	}
+	return false
+}
+
+//packets return a source reading the packets of a pod capture file matching the filter
+func (t *Timeline) packets(path string, src display.Source) (*source, error) {
+	in, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	filters := map[layers.LinkType]*filter.Filter{}
+	s := &source{f: in.f}
+	s.next = func() (bool, error) {
+		for {
+			ok, err := in.next()
+			if err != nil || !ok {
+				return false, err
+			}
+			linkType, entrySource := in.linkType, src
+			if in.ng != nil {
+				if intf, err := in.ng.Interface(in.ci.InterfaceIndex); err == nil {
+					linkType = intf.LinkType
+					// Pod pcapng files name their interfaces namespace/pod/interface
+					if i := strings.LastIndex(intf.Name, "/"); i >= 0 {
+						entrySource.Interface = intf.Name[i+1:]
+					}
+				}
+			}
+			f, ok := filters[linkType]
+			if !ok {
+				if f, err = t.opts.Filter.For(linkType); err != nil {
+					return false, fmt.Errorf("%s: %w", path, err)
+				}
+				filters[linkType] = f
+			}
+			packet := gopacket.NewPacket(in.data, linkType, gopacket.Default)
+			packet.Metadata().CaptureInfo = in.ci
+			if !f.Match(packet) {
+				continue
+			}
+			s.entry = Entry{Time: in.ci.Timestamp, Source: entrySource, Packet: packet}
+			return true, nil
+		}
+	}
+	return s, nil
+}
+
+//logs return a source reading the lines of a container log file, each prefixed by its timestamp
+func (t *Timeline) logs(path string, src display.Source, container string) (*source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// Lines before the first timestamp get the time of the first timestamped line
+	last, err := firstTimestamp(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r := bufio.NewReader(f)
+	s := &source{f: f}
+	s.next = func() (bool, error) {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return false, nil
+		}
+		if err != nil && err != io.EOF {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+		// Lines without a timestamp follow the previous one
+		ts, line, ok := splitTimestamp(strings.TrimRight(line, "\r\n"))
+		if ok {
+			last = ts
+		}
+		s.entry = Entry{Time: last, Source: src, Container: container, Line: line}
+		return true, nil
+	}
+	return s, nil
+}
+
+//firstTimestamp return the timestamp of the first timestamped line of a log file, or its modification time when none
+//of its lines has one, and rewind the file
+func firstTimestamp(f *os.File) (time.Time, error) {
+	r := bufio.NewReader(f)
+	var first time.Time
+	for first.IsZero() {
+		line, err := r.ReadString('\n')
+		if ts, _, ok := splitTimestamp(strings.TrimRight(line, "\r\n")); ok {
+			first = ts
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return first, err
+		}
+	}
+	if first.IsZero() {
+		info, err := f.Stat()
+		if err != nil {
+			return first, err
+		}
+		first = info.ModTime()
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return first, err
+}
+
+//splitTimestamp split a log line into its timestamp and its text, returning false for a line without timestamp
+func splitTimestamp(line string) (time.Time, string, bool) {
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			return ts, line[i+1:], true
+		}
+	}
+	return time.Time{}, line, false
+}
+
+//fileInterfaces return the interface captured in each pcap file of the session, from its summary
+func fileInterfaces(folder string) map[string]string {
+	interfaces := map[string]string{}
+	summary, err := session.ReadSummary(folder)
+	if err != nil {
+		return interfaces
+	}
+	for _, pod := range summary.Pods {
+		if pod.Interface != "" && pod.File != "" {
+			interfaces[strings.TrimSuffix(filepath.Base(pod.File), filepath.Ext(pod.File))] = pod.Interface
+		}
+	}
+	return interfaces
+}
+
+//interfaceOf return the interface of a pod pcap file, rotated files adding a timestamp to the name of the capture
+func interfaceOf(interfaces map[string]string, path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	best, intf := "", ""
+	for base, i := range interfaces {
+		if (name == base || strings.HasPrefix(name, base+"-")) && len(base) > len(best) {
+			best, intf = base, i
+		}
+	}
+	return intf
+}
@@ -0,0 +1,89 @@
+package merge
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+//writeLog write a container log file, each line prefixed by the timestamp of its millisecond offset unless negative
+func writeLog(t *testing.T, path string, lines map[int]string, order ...int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	b := strings.Builder{}
+	for _, offset := range order {
+		if offset >= 0 {
+			b.WriteString(epoch.Add(time.Duration(offset)*time.Millisecond).UTC().Format(time.RFC3339Nano) + " ")
+		}
+		b.WriteString(lines[offset] + "\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	folder := t.TempDir()
+	writePcap(t, filepath.Join(folder, "default", "web", "web.pcap"), layers.LinkTypeEthernet, 10, 30)
+	writePcap(t, filepath.Join(folder, "backend", "db", "db.pcap"), layers.LinkTypeEthernet, 25)
+	writeLog(t, filepath.Join(folder, "default", "web", "nginx.log"), map[int]string{0: "starting", 20: "GET /", -1: "  continued"}, 0, 20, -1)
+	writeLog(t, filepath.Join(folder, "default", "web", "nginx-previous.log"), map[int]string{5: "crashed"}, 5)
+	writeLog(t, filepath.Join(folder, "default", "web", "sidecar.log"), map[int]string{15: "ready"}, 15)
+	// The lines before the first timestamp take its time
+	writeLog(t, filepath.Join(folder, "backend", "db", "postgres.log"), map[int]string{-1: "banner", 27: "listening"}, -1, 27)
+	// The merged file repeats the pod packets
+	writePcap(t, filepath.Join(folder, "merged.pcap"), layers.LinkTypeEthernet, 10, 25, 30)
+
+	tests := []struct {
+		name    string
+		opts    TimelineOptions
+		entries []string
+	}{
+		{"all", TimelineOptions{}, []string{
+			"web nginx starting", "web nginx-previous crashed", "web packet 10", "web sidecar ready", "web nginx GET /", "web nginx   continued", "db packet 25", "db postgres banner", "db postgres listening", "web packet 30",
+		}},
+		{"pod", TimelineOptions{Pods: []string{"backend/db"}}, []string{"db packet 25", "db postgres banner", "db postgres listening"}},
+		{"pod name", TimelineOptions{Pods: []string{"web"}, Containers: []string{"sidecar"}}, []string{"web packet 10", "web sidecar ready", "web packet 30"}},
+		{"container and its previous logs", TimelineOptions{Pods: []string{"web"}, Containers: []string{"nginx"}}, []string{
+			"web nginx starting", "web nginx-previous crashed", "web packet 10", "web nginx GET /", "web nginx   continued", "web packet 30",
+		}},
+		{"time window", TimelineOptions{Since: epoch.Add(10 * time.Millisecond), Until: epoch.Add(25 * time.Millisecond)}, []string{
+			"web packet 10", "web sidecar ready", "web nginx GET /", "web nginx   continued", "db packet 25",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeline, err := OpenTimeline(folder, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer timeline.Close()
+			entries := []string{}
+			for {
+				entry, err := timeline.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if entry.Packet != nil {
+					entries = append(entries, fmt.Sprintf("%s packet %d", entry.Source.Pod, entry.Packet.Data()[0]))
+				} else {
+					entries = append(entries, entry.Source.Pod+" "+entry.Container+" "+entry.Line)
+				}
+			}
+			if strings.Join(entries, "\n") != strings.Join(tt.entries, "\n") {
+				t.Errorf("entries:\n%s\nwant:\n%s", strings.Join(entries, "\n"), strings.Join(tt.entries, "\n"))
+			}
+		})
+	}
+}
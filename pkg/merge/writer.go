@@ -0,0 +1,164 @@
+package merge
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+//DefaultBuffer is the number of packets waiting to be written before new ones are dropped
+const DefaultBuffer = 4096
+
+//DefaultWindow is the time packets are held to be written in the order of their timestamps
+const DefaultWindow = 500 * time.Millisecond
+
+//PacketWriter is an output shared by several captures
+type PacketWriter interface {
+	WritePacket(ci gopacket.CaptureInfo, data []byte) error
+}
+
+//Stats hold the counters of a writer
+type Stats struct {
+	Written uint64 `json:"written"`
+	Dropped uint64 `json:"dropped"`
+}
+
+type packet struct {
+	out      PacketWriter
+	ci       gopacket.CaptureInfo
+	data     []byte
+	received time.Time
+}
+
+//queue is a heap of packets ordered by timestamp
+type queue []packet
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].ci.Timestamp.Before(q[j].ci.Timestamp) }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(packet)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	p := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return p
+}
+
+//Writer write the packets of every capture to the shared outputs from a single goroutine.
+//Packets are held for a window to be written in the order of their timestamps, whatever the pod they come from.
+//Captures never wait for the outputs: when the buffer is full, their packets are dropped and counted
+type Writer struct {
+	written uint64
+	dropped uint64
+
+	mu      sync.RWMutex
+	closed  bool
+	packets chan packet
+	done    chan struct{}
+	window  time.Duration
+	buffer  int
+}
+
+//NewWriter start the goroutine writing the packets, buffer being the number of packets waiting to be written
+//and window the time they are held to be reordered, zero writing them as they arrive
+func NewWriter(buffer int, window time.Duration) *Writer {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	w := &Writer{packets: make(chan packet, buffer), done: make(chan struct{}), window: window, buffer: buffer}
+	go w.run()
+	return w
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	pending := &queue{}
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		// Release the oldest packet once it has waited for the whole window, or when the queue is full
+		now := time.Now()
+		for pending.Len() > 0 && ((*pending)[0].received.Add(w.window).Before(now) || pending.Len() > w.buffer) {
+			w.write(heap.Pop(pending).(packet))
+		}
+		if pending.Len() > 0 {
+			timer.Reset((*pending)[0].received.Add(w.window).Sub(now))
+		}
+
+		select {
+		case p, ok := <-w.packets:
+			if !ok {
+				for pending.Len() > 0 {
+					w.write(heap.Pop(pending).(packet))
+				}
+				return
+			}
+			if w.window <= 0 {
+				w.write(p)
+				continue
+			}
+			heap.Push(pending, p)
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Writer) write(p packet) {
+	if err := p.out.WritePacket(p.ci, p.data); err != nil {
+		// Closed outputs, such as a live viewer going away, already reported their error
+		if !errors.Is(err, os.ErrClosed) {
+			fmt.Println(err)
+		}
+		return
+	}
+	atomic.AddUint64(&w.written, 1)
+}
+
+//Output return a writer queuing the packets for out
+func (w *Writer) Output(out PacketWriter) PacketWriter {
+	return &output{writer: w, out: out}
+}
+
+type output struct {
+	writer *Writer
+	out    PacketWriter
+}
+
+//WritePacket queue a copy of the packet, it never blocks
+func (o *output) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	w := o.writer
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	select {
+	case w.packets <- packet{out: o.out, ci: ci, data: append([]byte(nil), data...), received: time.Now()}:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	return nil
+}
+
+//Stats return the packets written and dropped so far
+func (w *Writer) Stats() Stats {
+	return Stats{Written: atomic.LoadUint64(&w.written), Dropped: atomic.LoadUint64(&w.dropped)}
+}
+
+//Close write the queued packets and stop the goroutine, the outputs are left open
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.packets)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
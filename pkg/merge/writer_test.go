@@ -0,0 +1,82 @@
+package merge
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+//recorder keep the offsets of the packets written to it, from the goroutine of the writer
+type recorder struct {
+	offsets []int
+}
+
+func (r *recorder) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	r.offsets = append(r.offsets, int(data[0]))
+	return nil
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  time.Duration
+		offsets []int
+		written []int
+	}{
+		{"reordered", time.Minute, []int{30, 10, 20, 0}, []int{0, 10, 20, 30}},
+		{"no window", 0, []int{30, 10, 20, 0}, []int{30, 10, 20, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			w := NewWriter(16, tt.window)
+			// Packets of two captures, sharing an output
+			outputs := []PacketWriter{w.Output(rec), w.Output(rec)}
+			for i, offset := range tt.offsets {
+				data := []byte{byte(offset)}
+				ci := gopacket.CaptureInfo{Timestamp: epoch.Add(time.Duration(offset) * time.Millisecond), CaptureLength: 1, Length: 1}
+				if err := outputs[i%2].WritePacket(ci, data); err != nil {
+					t.Fatal(err)
+				}
+				// The packet is copied when queued
+				data[0] = 0xff
+			}
+			w.Close()
+
+			if stats := w.Stats(); stats.Written != uint64(len(tt.written)) || stats.Dropped != 0 {
+				t.Errorf("Stats() = %+v", stats)
+			}
+			if len(rec.offsets) != len(tt.written) {
+				t.Fatalf("written %v, want %v", rec.offsets, tt.written)
+			}
+			for i := range rec.offsets {
+				if rec.offsets[i] != tt.written[i] {
+					t.Fatalf("written %v, want %v", rec.offsets, tt.written)
+				}
+			}
+			if err := outputs[0].WritePacket(gopacket.CaptureInfo{}, []byte{0}); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("WritePacket() after Close = %v, want %v", err, os.ErrClosed)
+			}
+		})
+	}
+}
+
+func TestWriterWindow(t *testing.T) {
+	w := NewWriter(16, 20*time.Millisecond)
+	defer w.Close()
+	out := w.Output(&recorder{})
+	if err := out.WritePacket(gopacket.CaptureInfo{Timestamp: epoch, CaptureLength: 1, Length: 1}, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	// The packet is released once it waited for the window, without waiting for the next one
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Stats().Written == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the packet was never written")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
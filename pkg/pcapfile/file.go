@@ -0,0 +1,469 @@
+package pcapfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+//DefaultSnaplen is the snapshot length written in the file headers when none is given, large enough for jumbo frames
+const DefaultSnaplen = 262144
+
+const (
+	fileHeaderSize   = 24
+	packetHeaderSize = 16
+	timestampLayout  = "20060102T150405.000"
+)
+
+//Supported file formats
+const (
+	FormatPcap   = "pcap"
+	FormatPcapng = "pcapng"
+)
+
+//Options configure the format and the rotation of a capture file
+type Options struct {
+	//Format is either pcap, the default, or pcapng
+	Format   string
+	Snaplen  uint32
+	LinkType layers.LinkType
+	//Comment is written in the section header of pcapng files
+	Comment string
+	//RotateSize start a new file once the current one reaches this size in bytes
+	RotateSize int64
+	//RotateInterval start a new file once the current one is older than the interval
+	RotateInterval time.Duration
+	//RingFiles keep only the last files written, zero keeping all of them
+	RingFiles int
+}
+
+//Rotating return true when the files are rotated, and named after their creation time and sequence number
+func (o Options) Rotating() bool {
+	return o.RotateSize > 0 || o.RotateInterval > 0
+}
+
+//Validate check the consistency of the options
+func (o Options) Validate() error {
+	if o.RingFiles < 0 || o.RotateSize < 0 || o.RotateInterval < 0 {
+		return errors.New("rotation settings can't be negative")
+	}
+	if o.RingFiles > 0 && !o.Rotating() {
+		return errors.New("a ring buffer of files requires a rotation size or interval")
+	}
+	if o.Format != "" && o.Format != FormatPcap && o.Format != FormatPcapng {
+		return fmt.Errorf("unsupported format %q, expected pcap or pcapng", o.Format)
+	}
+	return nil
+}
+
+//Extension return the file extension matching the format
+func (o Options) Extension() string {
+	if o.Format == FormatPcapng {
+		return ".pcapng"
+	}
+	return ".pcap"
+}
+
+//File is a pcap or pcapng file rotated according to its options, it is safe for concurrent use
+type File struct {
+	mu      sync.Mutex
+	path    string
+	opts    Options
+	f       io.WriteCloser
+	w       *pcapgo.Writer
+	size    int64
+	packets int
+	opened  time.Time
+	files   []string
+	//sequence numbers the files of the rotation, so that two files opened in the same millisecond get different names
+	sequence   int
+	interfaces []Interface
+	//commented are the interfaces whose comment was written along with a packet of the current file
+	commented map[int]bool
+}
+
+//Create open a pcap file at path, or the first file of the rotation named after path
+func Create(path string, opts Options) (*File, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Snaplen == 0 {
+		opts.Snaplen = DefaultSnaplen
+	}
+	f := &File{path: path, opts: opts}
+	if err := f.open(time.Now()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+//NewStream write a capture to w, such as stdout or a named pipe, which is closed along with the stream.
+//Streams are not rotated, and every packet is written to w as soon as it is received
+func NewStream(w io.WriteCloser, opts Options) (*File, error) {
+	opts.RotateSize, opts.RotateInterval, opts.RingFiles = 0, 0, 0
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Snaplen == 0 {
+		opts.Snaplen = DefaultSnaplen
+	}
+	f := &File{opts: opts, f: w, opened: time.Now(), commented: map[int]bool{}}
+	if err := f.writeHeaders(w); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+//Path return the path the file was created with, with a .pcapng extension once it switched to pcapng
+func (f *File) Path() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.path
+}
+
+//name return the name of the next file of the rotation, its creation time followed by its sequence number
+func (f *File) name(t time.Time) string {
+	if !f.opts.Rotating() {
+		return f.path
+	}
+	f.sequence++
+	ext := filepath.Ext(f.path)
+	return fmt.Sprintf("%s-%s-%04d%s", strings.TrimSuffix(f.path, ext), t.Format(timestampLayout), f.sequence, ext)
+}
+
+func (f *File) open(t time.Time) error {
+	name := f.name(t)
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := f.writeHeaders(file); err != nil {
+		file.Close()
+		return err
+	}
+	f.f, f.opened, f.packets, f.commented = file, t, 0, map[int]bool{}
+	f.files = append(f.files, name)
+
+	// Drop the oldest files of the ring
+	for f.opts.RingFiles > 0 && len(f.files) > f.opts.RingFiles {
+		if err := os.Remove(f.files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		f.files = f.files[1:]
+	}
+	return nil
+}
+
+//writeHeaders write the file header, or the section header and the known interfaces of a pcapng file
+func (f *File) writeHeaders(file io.Writer) error {
+	if f.opts.Format != FormatPcapng {
+		f.w = pcapgo.NewWriter(file)
+		f.size = fileHeaderSize
+		return f.w.WriteFileHeader(f.opts.Snaplen, f.opts.LinkType)
+	}
+
+	size, err := writeSectionHeader(file, f.opts.Comment)
+	if err != nil {
+		return err
+	}
+	f.size = size
+	// Every file of a rotation describes the same interfaces with the same indexes
+	for _, intf := range f.interfaces {
+		size, err := writeInterface(file, intf)
+		if err != nil {
+			return err
+		}
+		f.size += size
+	}
+	return nil
+}
+
+//AddInterface describe a new capture interface and return its index.
+//Pcap files have a single link type, they are rewritten as pcapng when an interface of another link type is added
+func (f *File) AddInterface(intf Interface) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addInterface(intf)
+}
+
+func (f *File) addInterface(intf Interface) (int, error) {
+	if f.f == nil {
+		return 0, os.ErrClosed
+	}
+	if intf.LinkType == 0 {
+		intf.LinkType = f.opts.LinkType
+	}
+	if intf.Snaplen == 0 {
+		intf.Snaplen = f.opts.Snaplen
+	}
+	if f.opts.Format != FormatPcapng {
+		if intf.LinkType == f.opts.LinkType {
+			// The interfaces are kept in case the file switches to pcapng
+			f.interfaces = append(f.interfaces, intf)
+			return len(f.interfaces) - 1, nil
+		}
+		if f.path == "" {
+			return 0, fmt.Errorf("a pcap stream can't mix the %s and %s link types, use the pcapng format", f.opts.LinkType, intf.LinkType)
+		}
+		if err := f.upgrade(); err != nil {
+			return 0, err
+		}
+	}
+	size, err := writeInterface(f.f, intf)
+	if err != nil {
+		return 0, f.fail(err)
+	}
+	f.size += size
+	f.interfaces = append(f.interfaces, intf)
+	return len(f.interfaces) - 1, nil
+}
+
+//upgrade rewrite the current pcap file as pcapng, so that interfaces of other link types can be added.
+//Pcap files don't tell which interface their packets come from, the packets already written are attached to an interface named after the file.
+//A .pcap file is renamed .pcapng, along with the next files of the rotation
+func (f *File) upgrade() error {
+	err := f.f.Close()
+	f.f = nil
+	if err != nil {
+		return err
+	}
+
+	name := f.files[len(f.files)-1]
+	previous := Interface{
+		Name:        strings.TrimSuffix(filepath.Base(f.path), filepath.Ext(f.path)),
+		Description: "packets written before the file switched to pcapng",
+		LinkType:    f.opts.LinkType,
+		Snaplen:     f.opts.Snaplen,
+	}
+	upgraded := name
+	if filepath.Ext(name) == ".pcap" {
+		upgraded = strings.TrimSuffix(name, ".pcap") + ".pcapng"
+		f.path = strings.TrimSuffix(f.path, ".pcap") + ".pcapng"
+	}
+	f.opts.Format = FormatPcapng
+	f.interfaces = append(f.interfaces, previous)
+	if err := f.rewrite(name, upgraded, len(f.interfaces)-1); err != nil {
+		return fmt.Errorf("switching %s to pcapng: %w", name, err)
+	}
+	f.files[len(f.files)-1] = upgraded
+	file, err := os.OpenFile(upgraded, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	f.f, f.w = file, nil
+	return nil
+}
+
+//rewrite convert the pcap file at name to the pcapng file upgraded, its packets being attached to an interface
+func (f *File) rewrite(name, upgraded string, index int) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := pcapgo.NewReader(in)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(upgraded + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if err := f.writeHeaders(out); err != nil {
+		out.Close()
+		return err
+	}
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+		ci.InterfaceIndex = index
+		size, err := writeEnhancedPacket(out, ci, data, "")
+		if err != nil {
+			out.Close()
+			return err
+		}
+		f.size += size
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(out.Name(), upgraded); err != nil {
+		return err
+	}
+	if upgraded != name {
+		return os.Remove(name)
+	}
+	return nil
+}
+
+//Interface add a capture interface to the file and return a writer for its packets
+func (f *File) Interface(intf Interface) (*InterfaceWriter, error) {
+	index, err := f.AddInterface(intf)
+	if err != nil {
+		return nil, err
+	}
+	return &InterfaceWriter{file: f, index: index}, nil
+}
+
+//InterfaceWriter write packets to a file on behalf of one of its interfaces
+type InterfaceWriter struct {
+	file  *File
+	index int
+}
+
+//WritePacket write a packet captured on the interface
+func (w *InterfaceWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	ci.InterfaceIndex = w.index
+	return w.file.WritePacket(ci, data)
+}
+
+//MarkerLinkType is the link type of the interfaces holding markers, LINKTYPE_USER0
+const MarkerLinkType layers.LinkType = 147
+
+//Markers add an interface holding markers, such as kubernetes events, to a pcapng file and return a writer for them
+func (f *File) Markers(name string) (*MarkerWriter, error) {
+	f.mu.Lock()
+	format := f.opts.Format
+	f.mu.Unlock()
+	if format != FormatPcapng {
+		return nil, errors.New("markers are only written to pcapng files")
+	}
+	index, err := f.AddInterface(Interface{Name: name, LinkType: MarkerLinkType})
+	if err != nil {
+		return nil, err
+	}
+	return &MarkerWriter{file: f, index: index}, nil
+}
+
+//MarkerWriter write markers to an interface of a pcapng file.
+//Each marker is a packet holding its text, the text being its comment as well so that viewers list it
+type MarkerWriter struct {
+	file  *File
+	index int
+}
+
+//WritePacket write a marker whose text is data
+func (w *MarkerWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	ci.InterfaceIndex = w.index
+	return w.file.WritePacketComment(ci, data, string(data))
+}
+
+//rotate close the current file and open the next one if the packet would exceed the rotation settings
+func (f *File) rotate(now time.Time, size int64) error {
+	full := f.opts.RotateSize > 0 && f.packets > 0 && f.size+size > f.opts.RotateSize
+	expired := f.opts.RotateInterval > 0 && now.Sub(f.opened) >= f.opts.RotateInterval
+	if !full && !expired {
+		return nil
+	}
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+	if err := f.open(now); err != nil {
+		f.f = nil
+		return err
+	}
+	return nil
+}
+
+//WritePacket write a packet to the current file, rotating it first when needed.
+//In pcapng files, the packet is attached to the interface given by its InterfaceIndex
+func (f *File) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	return f.WritePacketComment(ci, data, "")
+}
+
+//WritePacketComment write a packet along with a comment, which is only kept in pcapng files
+func (f *File) WritePacketComment(ci gopacket.CaptureInfo, data []byte, comment string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return os.ErrClosed
+	}
+
+	if f.opts.Format != FormatPcapng {
+		size := int64(packetHeaderSize + len(data))
+		if err := f.rotate(time.Now(), size); err != nil {
+			return err
+		}
+		if err := f.w.WritePacket(ci, data); err != nil {
+			return f.fail(err)
+		}
+		f.size += size
+		f.packets++
+		return nil
+	}
+
+	if len(f.interfaces) == 0 {
+		if _, err := f.addInterface(Interface{LinkType: f.opts.LinkType}); err != nil {
+			return err
+		}
+	}
+	if ci.InterfaceIndex < 0 || ci.InterfaceIndex >= len(f.interfaces) {
+		return fmt.Errorf("unknown interface %d", ci.InterfaceIndex)
+	}
+	if err := f.rotate(time.Now(), int64(32+len(data)+padding(len(data))+optionsLength(stringOptions(nil, optionComment, comment)))); err != nil {
+		return err
+	}
+	// The first packet of each interface in a file carries the interface comment, so that viewers list it along with the packets
+	if comment == "" && !f.commented[ci.InterfaceIndex] {
+		comment = f.interfaces[ci.InterfaceIndex].Comment
+	}
+	size, err := writeEnhancedPacket(f.f, ci, data, comment)
+	if err != nil {
+		return f.fail(err)
+	}
+	f.commented[ci.InterfaceIndex] = true
+	f.size += size
+	f.packets++
+	return nil
+}
+
+//fail close a stream whose reader went away, so that the error is only reported once
+func (f *File) fail(err error) error {
+	if f.path == "" {
+		f.f.Close()
+		f.f = nil
+	}
+	return err
+}
+
+//Close close the current file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
+
+//Remove close the file and delete every file of the rotation still on disk, for captures which never started
+func (f *File) Remove() error {
+	err := f.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range f.files {
+		if rerr := os.Remove(name); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+	}
+	f.files = nil
+	return err
+}
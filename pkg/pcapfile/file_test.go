@@ -0,0 +1,183 @@
+package pcapfile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+func capture(n int) gopacket.CaptureInfo {
+	return gopacket.CaptureInfo{Timestamp: time.Unix(1600000000, 0).Add(time.Duration(n) * time.Millisecond), CaptureLength: 14, Length: 14}
+}
+
+func readPcap(t *testing.T, path string) int {
+	t.Helper()
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	r, err := pcapgo.NewReader(in)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	packets := 0
+	for {
+		if _, _, err := r.ReadPacketData(); err != nil {
+			return packets
+		}
+		packets++
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		valid bool
+	}{
+		{"default", Options{}, true},
+		{"pcapng", Options{Format: FormatPcapng}, true},
+		{"unknown format", Options{Format: "erf"}, false},
+		{"negative size", Options{RotateSize: -1}, false},
+		{"ring without rotation", Options{RingFiles: 3}, false},
+		{"ring with rotation", Options{RingFiles: 3, RotateInterval: time.Minute}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		ring    int
+		packets int
+		files   int
+	}{
+		{"every file kept", 0, 5, 5},
+		{"ring of two", 2, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Each packet fills a file, the files are all opened within the same millisecond
+			f, err := Create(filepath.Join(dir, "pod.pcap"), Options{LinkType: layers.LinkTypeEthernet, RotateSize: 1, RingFiles: tt.ring})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.packets; i++ {
+				if err := f.WritePacket(capture(i), make([]byte, 14)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "pod-*.pcap"))
+			sort.Strings(files)
+			if len(files) != tt.files {
+				t.Fatalf("got files %v, want %d", files, tt.files)
+			}
+			for _, file := range files {
+				if packets := readPcap(t, file); packets != 1 {
+					t.Errorf("%s holds %d packets, want 1", file, packets)
+				}
+			}
+			// The current file is the last one of the rotation
+			if last := f.files[len(f.files)-1]; files[len(files)-1] != last {
+				t.Errorf("last file is %s, want %s", files[len(files)-1], last)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir := t.TempDir()
+	f, err := Create(filepath.Join(dir, "pod.pcapng"), Options{Format: FormatPcapng, LinkType: layers.LinkTypeEthernet, RotateSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := f.WritePacket(capture(i), make([]byte, 14)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("files left after Remove(): %v", files)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() after Remove() = %v", err)
+	}
+}
+
+func TestPcapLinkTypeUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merged.pcap")
+	f, err := Create(path, Options{LinkType: layers.LinkTypeEthernet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth, err := f.Interface(Interface{Name: "default/web/eth0", LinkType: layers.LinkTypeEthernet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eth.WritePacket(capture(0), make([]byte, 14)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := f.Interface(Interface{Name: "default/web/tun0", LinkType: layers.LinkTypeRaw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := raw.WritePacket(capture(1), make([]byte, 20)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is renamed after its new format
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("%s is left after the switch to pcapng", path)
+	}
+	path = strings.TrimSuffix(path, ".pcap") + ".pcapng"
+	if f.Path() != path {
+		t.Errorf("Path() = %s, want %s", f.Path(), path)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	r, err := pcapgo.NewNgReader(in, pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	if err != nil {
+		t.Fatalf("the file was not switched to pcapng: %v", err)
+	}
+	linkTypes := []layers.LinkType{}
+	for {
+		_, ci, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		intf, err := r.Interface(ci.InterfaceIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		linkTypes = append(linkTypes, intf.LinkType)
+	}
+	if len(linkTypes) != 2 || linkTypes[0] != layers.LinkTypeEthernet || linkTypes[1] != layers.LinkTypeRaw {
+		t.Errorf("got link types %v, want ethernet then raw", linkTypes)
+	}
+}
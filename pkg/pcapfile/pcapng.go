@@ -0,0 +1,150 @@
+package pcapfile
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+//Interface describe a capture interface, written as an Interface Description Block in pcapng files
+type Interface struct {
+	Name        string
+	Description string
+	Comment     string
+	LinkType    layers.LinkType
+	Snaplen     uint32
+}
+
+//The blocks are written here rather than with pcapgo.NgWriter, which needs an interface before the section header is written,
+//buffers the file while live streams must see every packet, doesn't report the size of the blocks the rotation is based on,
+//and can't write packet comments
+const (
+	blockSectionHeader       = 0x0A0D0D0A
+	blockInterface           = 0x00000001
+	blockEnhancedPacket      = 0x00000006
+	byteOrderMagic           = 0x1A2B3C4D
+	optionEnd                = 0
+	optionComment            = 1
+	optionShbUserAppl        = 4
+	optionIfName             = 2
+	optionIfDescription      = 3
+	optionIfTsresol          = 9
+	nanosecondTsresol   byte = 9
+)
+
+type option struct {
+	code  uint16
+	value []byte
+}
+
+func padding(n int) int {
+	return (4 - n%4) % 4
+}
+
+func optionsLength(options []option) int {
+	if len(options) == 0 {
+		return 0
+	}
+	length := 4 // end of options
+	for _, o := range options {
+		length += 4 + len(o.value) + padding(len(o.value))
+	}
+	return length
+}
+
+func appendOptions(b []byte, options []option) []byte {
+	if len(options) == 0 {
+		return b
+	}
+	for _, o := range options {
+		b = appendUint16(b, o.code)
+		b = appendUint16(b, uint16(len(o.value)))
+		b = append(b, o.value...)
+		b = append(b, make([]byte, padding(len(o.value)))...)
+	}
+	b = appendUint16(b, optionEnd)
+	return appendUint16(b, 0)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func stringOptions(options []option, code uint16, value string) []option {
+	if value == "" {
+		return options
+	}
+	return append(options, option{code: code, value: []byte(value)})
+}
+
+//writeBlock write a block with its type, its body and its length on both ends, and return its size
+func writeBlock(w io.Writer, blockType uint32, body []byte) (int64, error) {
+	length := uint32(12 + len(body))
+	b := make([]byte, 0, length)
+	b = appendUint32(b, blockType)
+	b = appendUint32(b, length)
+	b = append(b, body...)
+	b = appendUint32(b, length)
+	_, err := w.Write(b)
+	return int64(length), err
+}
+
+func writeSectionHeader(w io.Writer, comment string) (int64, error) {
+	options := stringOptions(nil, optionComment, comment)
+	options = stringOptions(options, optionShbUserAppl, "kpture")
+
+	body := make([]byte, 0, 16+optionsLength(options))
+	body = appendUint32(body, byteOrderMagic)
+	body = appendUint16(body, 1)
+	body = appendUint16(body, 0)
+	// Unknown section length
+	body = appendUint32(body, 0xFFFFFFFF)
+	body = appendUint32(body, 0xFFFFFFFF)
+	body = appendOptions(body, options)
+	return writeBlock(w, blockSectionHeader, body)
+}
+
+func writeInterface(w io.Writer, intf Interface) (int64, error) {
+	options := stringOptions(nil, optionIfName, intf.Name)
+	options = stringOptions(options, optionIfDescription, intf.Description)
+	options = stringOptions(options, optionComment, intf.Comment)
+	options = append(options, option{code: optionIfTsresol, value: []byte{nanosecondTsresol}})
+
+	body := make([]byte, 0, 8+optionsLength(options))
+	body = appendUint16(body, uint16(intf.LinkType))
+	body = appendUint16(body, 0)
+	body = appendUint32(body, intf.Snaplen)
+	body = appendOptions(body, options)
+	return writeBlock(w, blockInterface, body)
+}
+
+func writeEnhancedPacket(w io.Writer, ci gopacket.CaptureInfo, data []byte, comment string) (int64, error) {
+	options := stringOptions(nil, optionComment, comment)
+
+	length := ci.Length
+	if length == 0 {
+		length = len(data)
+	}
+	ts := uint64(ci.Timestamp.UnixNano())
+
+	body := make([]byte, 0, 20+len(data)+padding(len(data))+optionsLength(options))
+	body = appendUint32(body, uint32(ci.InterfaceIndex))
+	body = appendUint32(body, uint32(ts>>32))
+	body = appendUint32(body, uint32(ts))
+	body = appendUint32(body, uint32(len(data)))
+	body = appendUint32(body, uint32(length))
+	body = append(body, data...)
+	body = append(body, make([]byte, padding(len(data)))...)
+	body = appendOptions(body, options)
+	return writeBlock(w, blockEnhancedPacket, body)
+}
@@ -0,0 +1,137 @@
+package pcapfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+//packetComments return the comment of every enhanced packet block of a pcapng file, empty for packets without one
+func packetComments(t *testing.T, b []byte) []string {
+	t.Helper()
+	comments := []string{}
+	for len(b) >= 12 {
+		blockType, length := binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint32(b[4:])
+		if length < 12 || int(length) > len(b) {
+			t.Fatalf("invalid block length %d", length)
+		}
+		if blockType == blockEnhancedPacket {
+			body := b[8 : length-4]
+			captured := int(binary.LittleEndian.Uint32(body[12:]))
+			options := body[20+captured+padding(captured):]
+			comment := ""
+			for len(options) >= 4 {
+				code, size := binary.LittleEndian.Uint16(options), int(binary.LittleEndian.Uint16(options[2:]))
+				if code == optionEnd {
+					break
+				}
+				if code == optionComment {
+					comment = string(options[4 : 4+size])
+				}
+				options = options[4+size+padding(size):]
+			}
+			comments = append(comments, comment)
+		}
+		b = b[length:]
+	}
+	return comments
+}
+
+func TestPcapngInterfaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "merged.pcapng")
+	f, err := Create(path, Options{Format: FormatPcapng, LinkType: layers.LinkTypeEthernet, Comment: "pod default/web node=n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, err := f.Interface(Interface{Name: "default/web/eth0", Comment: "node=n1 ips=10.0.0.1", LinkType: layers.LinkTypeEthernet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := f.Interface(Interface{Name: "default/db/eth0", Comment: "node=n2 ips=10.0.0.2", LinkType: layers.LinkTypeEthernet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	markers, err := f.Markers("kubernetes events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	marker := []byte("Warning Unhealthy Pod default/web")
+
+	writes := []struct {
+		write func() error
+		intf  string
+		// comment is the packet comment expected in the file
+		comment string
+	}{
+		{func() error { return web.WritePacket(capture(0), make([]byte, 14)) }, "default/web/eth0", "node=n1 ips=10.0.0.1"},
+		{func() error { return db.WritePacket(capture(1), make([]byte, 15)) }, "default/db/eth0", "node=n2 ips=10.0.0.2"},
+		{func() error { return web.WritePacket(capture(2), make([]byte, 16)) }, "default/web/eth0", ""},
+		{func() error {
+			ci := capture(3)
+			ci.CaptureLength, ci.Length = len(marker), len(marker)
+			return markers.WritePacket(ci, marker)
+		}, "kubernetes events", string(marker)},
+	}
+	for _, w := range writes {
+		if err := w.write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := pcapgo.NewNgReader(bytes.NewReader(b), pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment := r.SectionInfo().Comment; comment != "pod default/web node=n1" {
+		t.Errorf("section comment is %q", comment)
+	}
+	for i, w := range writes {
+		_, ci, err := r.ReadPacketData()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		intf, err := r.Interface(ci.InterfaceIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intf.Name != w.intf {
+			t.Errorf("packet %d is on interface %q, want %q", i, intf.Name, w.intf)
+		}
+		if !ci.Timestamp.Equal(capture(i).Timestamp) {
+			t.Errorf("packet %d has timestamp %v, want %v", i, ci.Timestamp, capture(i).Timestamp)
+		}
+	}
+
+	comments := packetComments(t, b)
+	if len(comments) != len(writes) {
+		t.Fatalf("got %d packets, want %d", len(comments), len(writes))
+	}
+	for i, w := range writes {
+		if comments[i] != w.comment {
+			t.Errorf("packet %d has comment %q, want %q", i, comments[i], w.comment)
+		}
+	}
+}
+
+func TestMarkersRequirePcapng(t *testing.T) {
+	f, err := Create(filepath.Join(t.TempDir(), "merged.pcap"), Options{LinkType: layers.LinkTypeEthernet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Markers("kubernetes events"); err == nil {
+		t.Error("markers were added to a pcap file")
+	}
+}
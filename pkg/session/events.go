@@ -0,0 +1,64 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+//ClusterEventsFile is the name of the file holding the kubernetes events of a session in the output folder
+const ClusterEventsFile = "events.jsonl"
+
+//StateChange is the type of the cluster events recorded when kpture sees the status of a pod or a node change
+const StateChange = "StateChange"
+
+//ClusterEvent is a kubernetes event, or a change of the status of a captured pod or of its node
+type ClusterEvent struct {
+	Time time.Time `json:"time"`
+	//Kind is Pod or Node
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Container string `json:"container,omitempty"`
+	//Type is Normal or Warning for kubernetes events, StateChange for status changes
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+	//Count is the number of occurrences of a kubernetes event
+	Count int32 `json:"count,omitempty"`
+}
+
+//String return a one line description of the event
+func (e ClusterEvent) String() string {
+	object := e.Kind + " " + e.Name
+	if e.Namespace != "" {
+		object = e.Kind + " " + e.Namespace + "/" + e.Name
+	}
+	if e.Container != "" {
+		object += " container " + e.Container
+	}
+	s := fmt.Sprintf("%s %s %s", e.Type, e.Reason, object)
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+//ClusterRecorder append cluster events as json lines to the events file of a session
+type ClusterRecorder struct {
+	*jsonLines
+}
+
+//NewClusterRecorder create the events file in the output folder
+func NewClusterRecorder(folder string) (*ClusterRecorder, error) {
+	lines, err := openJSONLines(filepath.Join(folder, ClusterEventsFile))
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterRecorder{lines}, nil
+}
+
+//Record write the event
+func (r *ClusterRecorder) Record(e ClusterEvent) error {
+	return r.write(e)
+}
@@ -0,0 +1,37 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+//jsonLines append values as json lines to a file of the session, from any goroutine
+type jsonLines struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+//openJSONLines open the file for appending, creating it when needed
+func openJSONLines(path string) (*jsonLines, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonLines{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+//write append the value as a line
+func (w *jsonLines) write(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(v)
+}
+
+//Close close the file
+func (w *jsonLines) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorders(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewClusterRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events are recorded from the goroutines of the captures
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder.Record(Event{Type: CaptureStarted, Namespace: "default", Pod: "web"})
+			cluster.Record(ClusterEvent{Time: time.Now(), Kind: "Pod", Namespace: "default", Name: "web", Type: StateChange, Reason: "Ready"})
+		}()
+	}
+	wg.Wait()
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file  string
+		value func() interface{}
+		check func(v interface{}) bool
+	}{
+		{MetadataFile, func() interface{} { return &Event{} }, func(v interface{}) bool {
+			e := v.(*Event)
+			return e.Type == CaptureStarted && e.Pod == "web" && !e.Time.IsZero()
+		}},
+		{ClusterEventsFile, func() interface{} { return &ClusterEvent{} }, func(v interface{}) bool {
+			e := v.(*ClusterEvent)
+			return e.Reason == "Ready" && e.String() == "StateChange Ready Pod default/web"
+		}},
+	}
+	for _, tt := range tests {
+		f, err := os.Open(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := 0
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			v := tt.value()
+			if err := json.Unmarshal(scanner.Bytes(), v); err != nil {
+				t.Fatalf("%s line %d: %v", tt.file, lines+1, err)
+			}
+			if !tt.check(v) {
+				t.Errorf("%s line %d: unexpected %s", tt.file, lines+1, scanner.Text())
+			}
+			lines++
+		}
+		f.Close()
+		if lines != 20 {
+			t.Errorf("%s holds %d lines, want 20", tt.file, lines)
+		}
+	}
+}
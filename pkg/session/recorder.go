@@ -0,0 +1,74 @@
+package session
+
+import (
+	"path/filepath"
+	"time"
+)
+
+//MetadataFile is the name of the session metadata file in the output folder
+const MetadataFile = "metadata.jsonl"
+
+//Event types recorded in the metadata file
+const (
+	PodRunning     = "pod_running"
+	PodTerminating = "pod_terminating"
+	PodStopped     = "pod_stopped"
+	CaptureStarted = "capture_started"
+	CaptureStopped = "capture_stopped"
+	CaptureFailed  = "capture_failed"
+	CaptureGap     = "capture_gap"
+)
+
+//Event represent one line of the metadata file
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Namespace string    `json:"namespace,omitempty"`
+	Pod       string    `json:"pod,omitempty"`
+	Interface string    `json:"interface,omitempty"`
+	Node      string    `json:"node,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	File      string    `json:"file,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	//ContainerID is the container captured, as reported by the proxy
+	ContainerID string `json:"container_id,omitempty"`
+	//Gap describe the time the connection of a capture was down, for capture_gap events
+	Gap *Gap `json:"gap,omitempty"`
+}
+
+//Gap describe a time during which the connection of a capture was down, the packets sent meanwhile being lost
+type Gap struct {
+	//Start is when the connection dropped, End when the capture resumed or gave up
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	//LastPacket is the timestamp of the last packet received before the connection dropped
+	LastPacket time.Time `json:"last_packet"`
+	//Reason is the error which ended the connection
+	Reason   string `json:"reason"`
+	Attempts int    `json:"attempts"`
+	Resumed  bool   `json:"resumed"`
+	//EstimatedLost is the number of packets possibly lost, from the packet rate before the gap
+	EstimatedLost uint64 `json:"estimated_lost"`
+}
+
+//Recorder append events as json lines to the metadata file of a session
+type Recorder struct {
+	*jsonLines
+}
+
+//NewRecorder create the metadata file in the output folder
+func NewRecorder(folder string) (*Recorder, error) {
+	lines, err := openJSONLines(filepath.Join(folder, MetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{lines}, nil
+}
+
+//Record write the event, its time is set to now when empty
+func (r *Recorder) Record(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	return r.write(e)
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+//SummaryFile is the name of the session summary file in the output folder
+const SummaryFile = "summary.json"
+
+//PodSummary hold the counters of the capture of a pod
+type PodSummary struct {
+	Namespace  string `json:"namespace"`
+	Pod        string `json:"pod"`
+	Interface  string `json:"interface,omitempty"`
+	File       string `json:"file"`
+	Packets    uint64 `json:"packets"`
+	Bytes      uint64 `json:"bytes"`
+	StopReason string `json:"stop_reason,omitempty"`
+	//Error is set when the capture ended on an error, such as an invalid frame
+	Error string `json:"error,omitempty"`
+	//Gaps count the times the connection of the capture was down
+	Gaps int `json:"gaps,omitempty"`
+}
+
+//Summary describe a finished capture session
+type Summary struct {
+	Start      time.Time    `json:"start"`
+	End        time.Time    `json:"end"`
+	StopReason string       `json:"stop_reason"`
+	Packets    uint64       `json:"packets"`
+	Bytes      uint64       `json:"bytes"`
+	Pods       []PodSummary `json:"pods"`
+	//MergedDropped count the packets missing from the merged files because they could not keep up
+	MergedDropped uint64 `json:"merged_dropped,omitempty"`
+}
+
+//Add append the counters of a pod to the summary
+func (s *Summary) Add(pod PodSummary) {
+	s.Pods = append(s.Pods, pod)
+	s.Packets += pod.Packets
+	s.Bytes += pod.Bytes
+}
+
+//Write save the summary in the output folder
+func (s *Summary) Write(folder string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(folder, SummaryFile), append(b, '\n'), 0644)
+}
+
+//ReadSummary load the summary of the session saved in the folder
+func ReadSummary(folder string) (*Summary, error) {
+	b, err := os.ReadFile(filepath.Join(folder, SummaryFile))
+	if err != nil {
+		return nil, err
+	}
+	s := &Summary{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", SummaryFile, err)
+	}
+	return s, nil
+}
+
+//Print write a human readable version of the summary
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Captured %d packets (%d bytes) in %s, %s\n", s.Packets, s.Bytes, s.End.Sub(s.Start).Round(time.Millisecond), s.StopReason)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, pod := range s.Pods {
+		reason := pod.StopReason
+		if pod.Gaps > 0 {
+			reason += fmt.Sprintf(" (%d gaps)", pod.Gaps)
+		}
+		name := pod.Namespace + "/" + pod.Pod
+		if pod.Interface != "" {
+			name += "/" + pod.Interface
+		}
+		fmt.Fprintf(tw, "  %s\t%d packets\t%d bytes\t%s\t%s\n", name, pod.Packets, pod.Bytes, pod.File, reason)
+	}
+	tw.Flush()
+	if s.MergedDropped > 0 {
+		fmt.Fprintf(w, "%d packets were dropped from the merged files, see --merge-buffer\n", s.MergedDropped)
+	}
+}
@@ -2,99 +2,359 @@ package socket
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/binary"
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"math"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/fatih/color"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcapgo"
+	"github.com/kpture/kpture/pkg/display"
+	"github.com/kpture/kpture/pkg/filter"
+	"github.com/kpture/kpture/pkg/pcapfile"
+	"github.com/kpture/kpture/pkg/session"
 )
 
-func handleConn(Conn net.Conn, Writer *pcapgo.Writer, capture Capture, c color.Attribute, MergedFile *pcapgo.Writer) {
-	reader := bufio.NewReader(Conn)
-
-	var buf bytes.Buffer
-	buf.Grow(1024)
+//DrainTimeout is the time given to in-flight frames to be received once a capture is stopped
+var DrainTimeout = 2 * time.Second
 
+//handleConn write the frames received from the proxy until the connection ends, a limit is reached or a frame is invalid.
+//It returns the error which ended the connection, nil when a limit was reached
+func (s *Stream) handleConn(frames *FrameReader, writer PacketWriter, writers []PacketWriter, opts Options) error {
 	for {
-		data, err := reader.ReadByte()
-		if err == nil {
-			if err == io.EOF {
-				break
-			}
+		ci, data, err := frames.ReadFrame()
+		if err != nil {
+			return err
+		}
+		if s.first.IsZero() {
+			s.first = ci.Timestamp
+		}
+		s.last = ci.Timestamp
+		// Older capture pods don't know about the snapshot length asked for
+		if snaplen := int(s.Capture.Snaplen); snaplen > 0 && len(data) > snaplen {
+			data = data[:snaplen]
+			ci.CaptureLength = snaplen
 		}
-		buf.WriteByte(data)
-		if buf.Len() > 16 {
-			lenght := int(binary.LittleEndian.Uint32(buf.Bytes()[8:12])) + 16
-			if buf.Len() >= lenght {
-				b := buf.Bytes()
-				packet := buf.Next(lenght)
-				Info := gopacket.CaptureInfo{}
-				g := binary.LittleEndian.Uint32(packet[0:4])
-				n := binary.LittleEndian.Uint32(packet[4:8]) * 1000
-				Info.Timestamp = time.Unix(int64(g), int64(n)).UTC()
-				Info.CaptureLength = int(binary.LittleEndian.Uint32(packet[8:12]))
-				Info.Length = int(binary.LittleEndian.Uint32(packet[12:16]))
-				err := Writer.WritePacket(Info, packet[16:])
-				err = MergedFile.WritePacket(Info, packet[16:])
-				p := gopacket.NewPacket(packet[16:], layers.LayerTypeEthernet, gopacket.Default)
-				// fmt.Println(p.NetworkLayer().NetworkFlow().String())
-				fmt.Println(p)
-				if err != nil {
-					fmt.Println(err)
-					fmt.Println(string(b))
+		p := gopacket.NewPacket(data, opts.Interface.LinkType, gopacket.Default)
+		p.Metadata().CaptureInfo = ci
+
+		// Files and console select their packets independently
+		if opts.WriteFilter.Match(p) {
+			if !opts.Counter.take(ci.CaptureLength) {
+				s.setReason(ReasonStopped)
+				return nil
+			}
+			err := writer.WritePacket(ci, data)
+			for _, merged := range writers {
+				if errm := merged.WritePacket(ci, data); errm != nil {
+					err = errm
 				}
 			}
+			// Outputs closed during the session, such as a live viewer going away, already reported their error
+			if err != nil && !errors.Is(err, os.ErrClosed) {
+				fmt.Println(s.Capture.ContainerNamespace+"/"+s.Capture.ContainerName, err)
+			}
+			opts.Events.Print(opts.Source, p)
+			atomic.AddUint64(&s.packets, 1)
+			atomic.AddUint64(&s.bytes, uint64(ci.CaptureLength))
+		}
+		if opts.DisplayFilter.Match(p) {
+			opts.Printer.Print(opts.Source, p)
+		}
+
+		stats := s.Stats()
+		if opts.Limits.Packets > 0 && stats.Packets >= opts.Limits.Packets {
+			s.setReason(ReasonMaxPackets)
+			return nil
+		}
+		if opts.Limits.Bytes > 0 && stats.Bytes >= opts.Limits.Bytes {
+			s.setReason(ReasonMaxBytes)
+			return nil
 		}
 	}
 }
 
-func StartCapture(capture Capture, url string, Writer *pcapgo.Writer) {
-	c, err := net.Dial("tcp", url)
+//readFailed record why reading the frames stopped, and keep the error when the capture did not end normally
+func (s *Stream) readFailed(err error) {
+	var nerr net.Error
+	switch {
+	case err == io.EOF:
+		s.setReason(ReasonClosed)
+		return
+	case errors.As(err, &nerr) && nerr.Timeout(), errors.Is(err, net.ErrClosed):
+		s.setReason(ReasonStopped)
+		return
+	case errors.Is(err, syscall.ECONNRESET):
+		s.setReason(ReasonReset)
+	case err == io.ErrUnexpectedEOF:
+		err = fmt.Errorf("connection closed in the middle of a frame: %w", err)
+		s.setReason(err.Error())
+	default:
+		s.setReason(err.Error())
+	}
+	fmt.Println(s.Capture.ContainerNamespace+"/"+s.Capture.ContainerName, err)
+	s.mu.Lock()
+	s.readErr = err
+	s.mu.Unlock()
+}
 
-	pcolor := rand.Intn(38-30) + 30
-	var patr color.Attribute
+//Stats hold the counters of a capture
+type Stats struct {
+	Packets uint64
+	Bytes   uint64
+}
 
-	switch pcolor {
-	case 30:
-		patr = color.FgGreen
-	case 31:
-		patr = color.FgCyan
-	case 32:
-		patr = color.FgHiYellow
-	default:
-		patr = color.FgRed
+//Stream represent a running capture of a container
+type Stream struct {
+	packets uint64
+	bytes   uint64
+
+	Capture Capture
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+
+	// Timestamps of the first and last frames, only used by the goroutine receiving the frames
+	first time.Time
+	last  time.Time
+
+	mu       sync.Mutex
+	reason   string
+	readErr  error
+	response *Response
+	gaps     []session.Gap
+}
+
+//PacketWriter is implemented by the outputs of a capture
+type PacketWriter interface {
+	WritePacket(ci gopacket.CaptureInfo, data []byte) error
+}
+
+//Options configure where the packets of a capture are written and when it stops
+type Options struct {
+	//File configure the format and rotation of the capture file
+	File pcapfile.Options
+	//Output is the capture file when it is shared with the captures of the other interfaces of the pod, it is left open.
+	//A file is created at the file name of the capture otherwise
+	Output *pcapfile.File
+	//Interface describe the captured interface in pcapng files
+	Interface pcapfile.Interface
+	//Outputs return the writers of the files shared with other captures, once the interface of the capture is known.
+	//They receive every packet along with the capture file
+	Outputs func(intf pcapfile.Interface) []PacketWriter
+	//Limits stop this capture once reached
+	Limits Limits
+	//Counter is shared by the captures of a session to enforce global limits
+	Counter *Counter
+	//WriteFilter select the packets written to the files, DisplayFilter the packets printed on the console
+	WriteFilter   *filter.Filter
+	DisplayFilter *filter.Filter
+	//Printer write the packets to the console, nothing is printed when it is nil
+	Printer *display.Printer
+	//Events receive the metadata of the packets written to the files
+	Events *display.Printer
+	//Source describe the captured pod in the console and the events
+	Source display.Source
+	//Reconnect configure how the capture reconnects when its connection drops, the zero value never reconnecting
+	Reconnect Backoff
+	//OnGap is called once a capture whose connection dropped is back, or gave up
+	OnGap func(gap session.Gap)
+	//TLS encrypt the connections to the proxy, nil using plain TCP
+	TLS *tls.Config
+	//Token authenticate the client to the proxy, it is only sent over TLS
+	Token string
+}
+
+func (s *Stream) setReason(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reason == "" {
+		s.reason = reason
+	}
+}
+
+//Reason return why the capture stopped, or an empty string while it is running
+func (s *Stream) Reason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+//Err return the error which ended the capture, such as an invalid frame or a reset connection.
+//It is nil while the capture runs and when it ended normally
+func (s *Stream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readErr
+}
+
+//Response return the answer of the server to the handshake, nil for servers older than the handshake
+func (s *Stream) Response() *Response {
+	return s.response
+}
+
+//Done return a channel closed once the capture ended and its file, unless shared, is closed
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+//Stats return the packets and bytes captured so far
+func (s *Stream) Stats() Stats {
+	return Stats{Packets: atomic.LoadUint64(&s.packets), Bytes: atomic.LoadUint64(&s.bytes)}
+}
+
+//Close stop the capture, wait for the in-flight frames to be written and close the capture file
+func (s *Stream) Close() error {
+	s.cancel()
+	<-s.done
+	return s.err
+}
+
+//negotiate apply the link type and the snapshot length reported by the server to the options,
+//and return the largest frame expected. Servers older than the handshake keep the link type of the file options
+func negotiate(response *Response, opts *Options) (uint32, error) {
+	if opts.File.LinkType == 0 {
+		opts.File.LinkType = layers.LinkTypeEthernet
 	}
+	snaplen := uint32(MaxSnaplen)
+	if response != nil {
+		if response.LinkType > math.MaxUint8 {
+			return 0, fmt.Errorf("unsupported link type %d", response.LinkType)
+		}
+		if response.LinkType != 0 {
+			opts.File.LinkType = layers.LinkType(response.LinkType)
+		}
+		if response.Snaplen != 0 {
+			snaplen, opts.File.Snaplen = response.Snaplen, response.Snaplen
+		}
+	}
+	opts.Interface.LinkType, opts.Interface.Snaplen = opts.File.LinkType, opts.File.Snaplen
 
-	color.New()
+	// BPF expressions run on the link layer of the capture
+	var err error
+	if opts.WriteFilter, err = opts.WriteFilter.For(opts.File.LinkType); err != nil {
+		return 0, err
+	}
+	if opts.DisplayFilter, err = opts.DisplayFilter.For(opts.File.LinkType); err != nil {
+		return 0, err
+	}
+	return snaplen, nil
+}
+
+//connect dial the proxy, over TLS when the options configure it, and open the capture with a handshake.
+//r buffers the frames which follow the response
+func connect(ctx context.Context, capture Capture, url string, opts Options) (c net.Conn, r *bufio.Reader, response *Response, err error) {
+	token := ""
+	dialer := &net.Dialer{Timeout: DialTimeout}
+	if opts.TLS != nil {
+		d := tls.Dialer{NetDialer: dialer, Config: opts.TLS}
+		if c, err = d.DialContext(ctx, "tcp", url); err != nil {
+			return nil, nil, nil, fmt.Errorf("TLS connection to %s: %w", url, err)
+		}
+		token = opts.Token
+	} else {
+		c, err = dialer.DialContext(ctx, "tcp", url)
+	}
 	if err != nil {
-		fmt.Println(err)
-		return
+		return nil, nil, nil, err
+	}
+	r = bufio.NewReader(c)
+	response, err = handshake(c, r, capture, token)
+	if err != nil {
+		c.Close()
+		return nil, nil, nil, err
 	}
+	return c, r, response, nil
+}
 
-	f, err := os.Create(capture.FileName)
+//StartCapture dial the proxy, open the capture with a handshake and write the captured packets to the capture file
+//and to the outputs of the options, with the link type and snapshot length reported by the server. A HandshakeError is returned when the server refuses the capture.
+//The capture runs until the context is cancelled, a limit is reached or the connection is closed by the proxy.
+//A dropped connection is reopened according to the Reconnect options, the packets being appended to the same files
+func StartCapture(ctx context.Context, capture Capture, url string, opts Options) (*Stream, error) {
+	c, r, response, err := connect(ctx, capture, url, opts)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return nil, err
 	}
-	wf := pcapgo.NewWriter(f)
-	wf.WriteFileHeader(1024, layers.LinkTypeEthernet)
 
-	go handleConn(c, wf, capture, patr, Writer)
+	snaplen, err := negotiate(response, &opts)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
 
-	b, err := json.Marshal(capture)
+	f := opts.Output
+	if f == nil {
+		if f, err = pcapfile.Create(capture.FileName, opts.File); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	w, err := f.Interface(opts.Interface)
 	if err != nil {
-		fmt.Println(err)
-		return
+		c.Close()
+		if opts.Output == nil {
+			f.Close()
+		}
+		return nil, err
+	}
+	writers := []PacketWriter{}
+	if opts.Outputs != nil {
+		writers = opts.Outputs(opts.Interface)
 	}
-	c.Write(b)
 
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{Capture: capture, cancel: cancel, done: make(chan struct{}), response: response}
+	go func() {
+		frames := NewFrameReader(r, snaplen)
+		for c != nil {
+			err := s.receive(ctx, c, frames, w, writers, opts)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil || opts.Reconnect.Attempts == 0 {
+				s.readFailed(err)
+				break
+			}
+			c, frames = s.reconnect(ctx, url, err, opts)
+		}
+		cancel()
+		if opts.Output == nil {
+			s.err = f.Close()
+		}
+		close(s.done)
+	}()
+	return s, nil
+}
+
+//receive write the packets of a connection until it ends, and return the error which ended it, nil when the capture stopped
+func (s *Stream) receive(ctx context.Context, c net.Conn, frames *FrameReader, w PacketWriter, writers []PacketWriter, opts Options) error {
+	defer c.Close()
+	received := make(chan error, 1)
+	go func() {
+		received <- s.handleConn(frames, w, writers, opts)
+	}()
+	select {
+	case err := <-received:
+		return err
+	case <-ctx.Done():
+		s.setReason(ReasonStopped)
+		// Stop asking for packets and let the frames already sent reach the files
+		if cw, ok := c.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		c.SetReadDeadline(time.Now().Add(DrainTimeout))
+		if err := <-received; err != nil {
+			s.readFailed(err)
+		}
+		return nil
+	}
 }
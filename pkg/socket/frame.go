@@ -0,0 +1,78 @@
+package socket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+//FrameHeaderSize is the size of the header sent by the proxy before each packet:
+//seconds, microseconds, captured length and original length, as little endian 32 bits integers
+const FrameHeaderSize = 16
+
+//MaxSnaplen is the largest packet accepted from the proxy when no snapshot length is known
+const MaxSnaplen = 262144
+
+//ErrInvalidFrame is returned for frames whose header can't be trusted
+var ErrInvalidFrame = errors.New("invalid frame")
+
+//FrameReader read the packets sent by the proxy
+type FrameReader struct {
+	r       io.Reader
+	snaplen uint32
+	header  [FrameHeaderSize]byte
+	buf     []byte
+}
+
+//NewFrameReader return a reader rejecting the packets larger than snaplen
+func NewFrameReader(r io.Reader, snaplen uint32) *FrameReader {
+	if snaplen == 0 || snaplen > MaxSnaplen {
+		snaplen = MaxSnaplen
+	}
+	return &FrameReader{r: r, snaplen: snaplen}
+}
+
+//ReadFrame return the next packet, whose data is only valid until the next call.
+//It returns io.EOF when the stream ends between two frames, and io.ErrUnexpectedEOF in the middle of a frame
+func (f *FrameReader) ReadFrame() (gopacket.CaptureInfo, []byte, error) {
+	ci := gopacket.CaptureInfo{}
+	if _, err := io.ReadFull(f.r, f.header[:]); err != nil {
+		return ci, nil, err
+	}
+	seconds := binary.LittleEndian.Uint32(f.header[0:4])
+	micros := binary.LittleEndian.Uint32(f.header[4:8])
+	caplen := binary.LittleEndian.Uint32(f.header[8:12])
+	length := binary.LittleEndian.Uint32(f.header[12:16])
+
+	switch {
+	case micros >= 1000000:
+		return ci, nil, fmt.Errorf("%w: %d microseconds", ErrInvalidFrame, micros)
+	case caplen > f.snaplen:
+		return ci, nil, fmt.Errorf("%w: captured length %d exceeds the snapshot length %d", ErrInvalidFrame, caplen, f.snaplen)
+	case length != 0 && length < caplen:
+		return ci, nil, fmt.Errorf("%w: captured length %d exceeds the packet length %d", ErrInvalidFrame, caplen, length)
+	}
+	if length == 0 {
+		length = caplen
+	}
+
+	if cap(f.buf) < int(caplen) {
+		f.buf = make([]byte, caplen)
+	}
+	data := f.buf[:caplen]
+	if _, err := io.ReadFull(f.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return ci, nil, err
+	}
+
+	ci.Timestamp = time.Unix(int64(seconds), int64(micros)*1000).UTC()
+	ci.CaptureLength = int(caplen)
+	ci.Length = int(length)
+	return ci, data, nil
+}
@@ -0,0 +1,104 @@
+package socket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+//frame encode a frame as sent by the proxy
+func frame(seconds, micros, caplen, length uint32, data []byte) []byte {
+	b := make([]byte, FrameHeaderSize, FrameHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(b[0:4], seconds)
+	binary.LittleEndian.PutUint32(b[4:8], micros)
+	binary.LittleEndian.PutUint32(b[8:12], caplen)
+	binary.LittleEndian.PutUint32(b[12:16], length)
+	return append(b, data...)
+}
+
+func TestReadFrame(t *testing.T) {
+	packet := []byte("0123456789")
+	tests := []struct {
+		name    string
+		stream  []byte
+		snaplen uint32
+		// err is the error of the first frame, nil when it is read
+		err    error
+		length int
+	}{
+		{"frame", frame(1600000000, 500, 10, 60, packet), 0, nil, 60},
+		{"length defaults to caplen", frame(1600000000, 500, 10, 0, packet), 0, nil, 10},
+		{"empty stream", nil, 0, io.EOF, 0},
+		{"truncated header", frame(1600000000, 500, 10, 10, nil)[:7], 0, io.ErrUnexpectedEOF, 0},
+		{"eof mid-frame", frame(1600000000, 500, 10, 10, packet[:4]), 0, io.ErrUnexpectedEOF, 0},
+		{"eof after header", frame(1600000000, 500, 10, 10, nil), 0, io.ErrUnexpectedEOF, 0},
+		{"caplen above snaplen", frame(1600000000, 500, 10, 10, packet), 8, ErrInvalidFrame, 0},
+		{"caplen above max snaplen", frame(1600000000, 500, MaxSnaplen+1, MaxSnaplen+1, nil), 0, ErrInvalidFrame, 0},
+		{"caplen above length", frame(1600000000, 500, 10, 9, packet), 0, ErrInvalidFrame, 0},
+		{"one second of micros", frame(1600000000, 1000000, 10, 10, packet), 0, ErrInvalidFrame, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci, data, err := NewFrameReader(bytes.NewReader(tt.stream), tt.snaplen).ReadFrame()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ReadFrame() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(data, packet) {
+				t.Errorf("data = %q, want %q", data, packet)
+			}
+			if want := time.Unix(1600000000, 500000).UTC(); !ci.Timestamp.Equal(want) {
+				t.Errorf("timestamp = %v, want %v", ci.Timestamp, want)
+			}
+			if ci.CaptureLength != len(packet) || ci.Length != tt.length {
+				t.Errorf("lengths = %d/%d, want %d/%d", ci.CaptureLength, ci.Length, len(packet), tt.length)
+			}
+		})
+	}
+}
+
+func TestReadFrames(t *testing.T) {
+	stream := append(frame(1, 0, 3, 3, []byte("abc")), frame(2, 0, 0, 0, nil)...)
+	stream = append(stream, frame(3, 0, 5, 1500, []byte("defgh"))...)
+	frames := NewFrameReader(bytes.NewReader(stream), 0)
+	for _, want := range []string{"abc", "", "defgh"} {
+		_, data, err := frames.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("data = %q, want %q", data, want)
+		}
+	}
+	if _, _, err := frames.ReadFrame(); err != io.EOF {
+		t.Errorf("end of stream returned %v, want io.EOF", err)
+	}
+}
+
+//FuzzFrameReader check that malformed streams of frames are rejected without panicking,
+//and that the frames read are consistent with their header
+func FuzzFrameReader(f *testing.F) {
+	f.Add(frame(1600000000, 500, 10, 60, []byte("0123456789")))
+	f.Add(append(frame(1, 0, 3, 3, []byte("abc")), frame(2, 0, 0, 0, nil)...))
+	f.Add(frame(1600000000, 1000000, 10, 10, nil)[:9])
+	f.Fuzz(func(t *testing.T, stream []byte) {
+		frames := NewFrameReader(bytes.NewReader(stream), 4096)
+		for {
+			ci, data, err := frames.ReadFrame()
+			switch {
+			case err == io.EOF, err == io.ErrUnexpectedEOF, errors.Is(err, ErrInvalidFrame):
+				return
+			case err != nil:
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(data) != ci.CaptureLength || ci.CaptureLength > 4096 || ci.CaptureLength > ci.Length {
+				t.Fatalf("frame of %d bytes inconsistent with its header %+v", len(data), ci)
+			}
+		}
+	})
+}
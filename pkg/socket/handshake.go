@@ -0,0 +1,153 @@
+package socket
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+//ProtocolVersion is the version of the capture protocol spoken by the client
+const ProtocolVersion = 1
+
+//Capabilities announced by the client in its hello
+const (
+	CapabilityFilter = "filter"
+)
+
+//HandshakeTimeout is the time given to the server to answer the hello. Servers answer as soon as the capture started or was
+//refused, servers older than the handshake never answer: their captures start once it expires, or as soon as a frame comes
+var HandshakeTimeout = time.Second
+
+//DialTimeout bound the connection to the proxy, including the TLS handshake that servers without TLS never answer
+var DialTimeout = 3 * time.Second
+
+//responsePrefix start every response, it can't be mistaken for a frame header whose microseconds would be out of range
+const responsePrefix = `{"version":`
+
+//Hello open a capture. The fields of the capture are inlined so that servers older than the handshake still read the request
+type Hello struct {
+	Version      int      `json:"version"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	//Token is a kubernetes bearer token the proxy validates with a TokenReview, only sent over TLS
+	Token string `json:"token,omitempty"`
+	Capture
+}
+
+//Response statuses
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+//Response error codes
+const (
+	CodeContainerNotFound  = "container_not_found"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeInvalidRequest     = "invalid_request"
+	CodeCaptureFailed      = "capture_failed"
+	CodeUnauthorized       = "unauthorized"
+)
+
+//Response is the answer of the server to a hello, followed by the frames when the capture started
+type Response struct {
+	Version      int      `json:"version"`
+	Status       string   `json:"status"`
+	Code         string   `json:"code,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	Node         string   `json:"node,omitempty"`
+	ContainerID  string   `json:"container_id,omitempty"`
+	LinkType     uint32   `json:"link_type,omitempty"`
+	Snaplen      uint32   `json:"snaplen,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+//Errors returned when the server refuses a capture, they are wrapped in a HandshakeError
+var (
+	ErrContainerNotFound  = errors.New("container not found")
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	ErrInvalidRequest     = errors.New("invalid capture request")
+	ErrCaptureFailed      = errors.New("capture failed")
+	ErrUnauthorized       = errors.New("capture not authorized")
+)
+
+//errHandshakeClosed is returned when the server closed the connection instead of answering the hello
+var errHandshakeClosed = errors.New("connection closed during the handshake")
+
+var codeErrors = map[string]error{
+	CodeContainerNotFound:  ErrContainerNotFound,
+	CodeUnsupportedVersion: ErrUnsupportedVersion,
+	CodeInvalidRequest:     ErrInvalidRequest,
+	CodeCaptureFailed:      ErrCaptureFailed,
+	CodeUnauthorized:       ErrUnauthorized,
+}
+
+//HandshakeError is returned when the server refused a capture
+type HandshakeError struct {
+	Response Response
+	Capture  Capture
+}
+
+func (e *HandshakeError) Error() string {
+	// The capture is left out, the callers print it in front of the error
+	msg := e.Unwrap().Error()
+	if e.Response.Node != "" {
+		msg += " on node " + e.Response.Node
+	}
+	if e.Response.Error != "" {
+		msg += ": " + e.Response.Error
+	}
+	return msg
+}
+
+//Unwrap return the error matching the code of the response, such as ErrContainerNotFound
+func (e *HandshakeError) Unwrap() error {
+	if err, ok := codeErrors[e.Response.Code]; ok {
+		return err
+	}
+	return ErrCaptureFailed
+}
+
+//handshake send the hello and read the response of the server, r buffering the frames which may follow.
+//A nil response is returned for servers older than the handshake
+func handshake(conn net.Conn, r *bufio.Reader, capture Capture, token string) (*Response, error) {
+	b, err := json.Marshal(Hello{Version: ProtocolVersion, Capabilities: []string{CapabilityFilter}, Token: token, Capture: capture})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(b, '\n')); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	prefix, err := r.Peek(len(responsePrefix))
+	var nerr net.Error
+	switch {
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return nil, nil
+	case err == io.EOF:
+		return nil, errHandshakeClosed
+	case err != nil:
+		return nil, err
+	case string(prefix) != responsePrefix:
+		// Frames sent by an older server
+		return nil, nil
+	}
+
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading the handshake response: %w", err)
+	}
+	response := &Response{}
+	if err := json.Unmarshal(line, response); err != nil {
+		return nil, fmt.Errorf("invalid handshake response: %w", err)
+	}
+	if response.Status != StatusOK {
+		return nil, &HandshakeError{Response: *response, Capture: capture}
+	}
+	return response, nil
+}
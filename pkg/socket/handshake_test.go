@@ -0,0 +1,78 @@
+package socket
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandshake(t *testing.T) {
+	defer func(timeout time.Duration) { HandshakeTimeout = timeout }(HandshakeTimeout)
+	HandshakeTimeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// reply is written by the server after reading the hello, the connection is closed after it when close is set
+		reply    string
+		close    bool
+		response bool
+		err      error
+	}{
+		{"answer", `{"version":1,"status":"ok","link_type":113,"snaplen":128}` + "\n", false, true, nil},
+		{"refused", `{"version":1,"status":"error","code":"container_not_found"}` + "\n", false, false, ErrContainerNotFound},
+		{"unknown code", `{"version":1,"status":"error","code":"new_code"}` + "\n", false, false, ErrCaptureFailed},
+		{"frames of an older server", string(frame(1600000000, 0, 4, 4, []byte("abcd"))), false, false, nil},
+		{"silent older server", "", false, false, nil},
+		{"closed", "", true, false, errHandshakeClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func(reply string, close bool) {
+				bufio.NewReader(server).ReadBytes('\n')
+				server.Write([]byte(reply))
+				if close {
+					server.Close()
+				}
+			}(tt.reply, tt.close)
+			defer server.Close()
+
+			start := time.Now()
+			response, err := handshake(client, bufio.NewReader(client), Capture{ContainerName: "web", ContainerNamespace: "default"}, "")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("handshake() error = %v, want %v", err, tt.err)
+			}
+			if (response != nil) != tt.response {
+				t.Fatalf("handshake() response = %+v, want a response %v", response, tt.response)
+			}
+			if response != nil && (response.LinkType != 113 || response.Snaplen != 128) {
+				t.Errorf("response = %+v", response)
+			}
+			// Only a silent server waits for the timeout
+			if elapsed := time.Since(start); tt.reply != "" && elapsed >= HandshakeTimeout {
+				t.Errorf("the handshake took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestHandshakeError(t *testing.T) {
+	capture := Capture{ContainerName: "web", ContainerNamespace: "default"}
+	tests := []struct {
+		response Response
+		msg      string
+	}{
+		{Response{Code: CodeContainerNotFound, Node: "node-1"}, "container not found on node node-1"},
+		{Response{Code: CodeCaptureFailed, Error: "no such device"}, "capture failed: no such device"},
+		{Response{Code: "new_code"}, "capture failed"},
+	}
+	for _, tt := range tests {
+		err := &HandshakeError{Response: tt.response, Capture: capture}
+		if msg := err.Error(); msg != tt.msg {
+			t.Errorf("%+v: Error() = %q, want %q", tt.response, msg, tt.msg)
+		}
+	}
+}
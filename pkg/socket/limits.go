@@ -0,0 +1,59 @@
+package socket
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//Limits stop a capture once a number of packets or bytes is reached, zero meaning unlimited
+type Limits struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+//IsZero return true when no limit is set
+func (l Limits) IsZero() bool {
+	return l.Packets == 0 && l.Bytes == 0
+}
+
+//Counter enforce limits over several streams, OnLimit is called once when a limit is reached
+type Counter struct {
+	packets uint64
+	bytes   uint64
+
+	Limits  Limits
+	OnLimit func(reason string)
+	once    sync.Once
+}
+
+//take account for a packet of the given size and return false when it exceeds the limits
+func (c *Counter) take(size int) bool {
+	if c == nil {
+		return true
+	}
+	packets := atomic.AddUint64(&c.packets, 1)
+	bytes := atomic.AddUint64(&c.bytes, uint64(size))
+
+	reason := ""
+	if c.Limits.Packets > 0 && packets > c.Limits.Packets {
+		reason = ReasonMaxPackets
+	} else if c.Limits.Bytes > 0 && bytes > c.Limits.Bytes {
+		reason = ReasonMaxBytes
+	}
+	if reason == "" {
+		return true
+	}
+	if c.OnLimit != nil {
+		c.once.Do(func() { c.OnLimit(reason) })
+	}
+	return false
+}
+
+//Reasons for which a capture stops
+const (
+	ReasonMaxPackets = "packet limit reached"
+	ReasonMaxBytes   = "byte limit reached"
+	ReasonClosed     = "connection closed by the proxy"
+	ReasonReset      = "connection reset by the proxy"
+	ReasonStopped    = "stopped"
+)
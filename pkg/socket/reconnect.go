@@ -0,0 +1,121 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/kpture/kpture/pkg/session"
+)
+
+//Backoff configure the reconnection of a capture whose connection dropped
+type Backoff struct {
+	//Delay is the time waited before the first attempt, doubled after each failed attempt up to MaxDelay
+	Delay    time.Duration
+	MaxDelay time.Duration
+	//Attempts is the number of attempts before giving up, zero never reconnecting
+	Attempts int
+}
+
+//DefaultBackoff retry for about two minutes before giving up
+var DefaultBackoff = Backoff{Delay: 500 * time.Millisecond, MaxDelay: 30 * time.Second, Attempts: 10}
+
+//delay return the time to wait before an attempt, starting at 1
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Delay
+	for i := 1; i < attempt && (b.MaxDelay <= 0 || d < b.MaxDelay); i++ {
+		d *= 2
+	}
+	if b.MaxDelay > 0 && d > b.MaxDelay {
+		d = b.MaxDelay
+	}
+	return d
+}
+
+//ErrLinkTypeChanged is returned when a capture reopened after a gap reports another link type than its files, it isn't retried
+var ErrLinkTypeChanged = errors.New("link type changed")
+
+//Gaps return the times the connection of the capture was down
+func (s *Stream) Gaps() []session.Gap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]session.Gap{}, s.gaps...)
+}
+
+//reconnect open the capture again once its connection dropped on cause, waiting longer after each failed attempt.
+//A nil connection is returned when the capture must stop, its reason being recorded
+func (s *Stream) reconnect(ctx context.Context, url string, cause error, opts Options) (net.Conn, *FrameReader) {
+	key := s.Capture.ContainerNamespace + "/" + s.Capture.ContainerName
+	gap := session.Gap{Start: time.Now(), LastPacket: s.last, Reason: cause.Error()}
+	fmt.Println(key, cause, "- reconnecting")
+
+	for {
+		gap.Attempts++
+		timer := time.NewTimer(opts.Reconnect.delay(gap.Attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.setReason(ReasonStopped)
+			s.endGap(&gap, opts)
+			return nil, nil
+		case <-timer.C:
+		}
+
+		c, r, response, err := connect(ctx, s.Capture, url, opts)
+		if err == nil {
+			var snaplen uint32
+			if snaplen, err = resume(response, opts); err == nil {
+				gap.Resumed = true
+				s.endGap(&gap, opts)
+				fmt.Println(key, "reconnected after", gap.End.Sub(gap.Start).Round(time.Millisecond))
+				return c, NewFrameReader(r, snaplen)
+			}
+			c.Close()
+		}
+
+		var herr *HandshakeError
+		switch {
+		case errors.Is(err, ErrContainerNotFound), errors.Is(err, errHandshakeClosed):
+			// The container ended along with the connection
+			s.endGap(&gap, opts)
+			s.readFailed(cause)
+			return nil, nil
+		case errors.As(err, &herr), errors.Is(err, ErrLinkTypeChanged), gap.Attempts >= opts.Reconnect.Attempts:
+			s.endGap(&gap, opts)
+			s.readFailed(fmt.Errorf("reconnection failed after %d attempts: %w", gap.Attempts, err))
+			return nil, nil
+		}
+	}
+}
+
+//resume check that the capture reopened after a gap still matches its files, and return the largest frame expected
+func resume(response *Response, opts Options) (uint32, error) {
+	if response == nil {
+		return MaxSnaplen, nil
+	}
+	if response.LinkType != 0 && layers.LinkType(response.LinkType) != opts.Interface.LinkType {
+		return 0, fmt.Errorf("%w from %s to %d", ErrLinkTypeChanged, opts.Interface.LinkType, response.LinkType)
+	}
+	if response.Snaplen != 0 {
+		return response.Snaplen, nil
+	}
+	return MaxSnaplen, nil
+}
+
+//endGap record a gap once the capture resumed or gave up
+func (s *Stream) endGap(gap *session.Gap, opts Options) {
+	gap.End = time.Now()
+	if elapsed := s.last.Sub(s.first); elapsed > 0 {
+		rate := float64(s.Stats().Packets) / elapsed.Seconds()
+		gap.EstimatedLost = uint64(rate * gap.End.Sub(gap.Start).Seconds())
+	}
+	s.mu.Lock()
+	s.gaps = append(s.gaps, *gap)
+	s.mu.Unlock()
+	if opts.OnGap != nil {
+		opts.OnGap(*gap)
+	}
+}
@@ -0,0 +1,193 @@
+package socket
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/kpture/kpture/pkg/session"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		backoff Backoff
+		attempt int
+		delay   time.Duration
+	}{
+		{Backoff{Delay: time.Second, MaxDelay: 5 * time.Second}, 1, time.Second},
+		{Backoff{Delay: time.Second, MaxDelay: 5 * time.Second}, 2, 2 * time.Second},
+		{Backoff{Delay: time.Second, MaxDelay: 5 * time.Second}, 3, 4 * time.Second},
+		{Backoff{Delay: time.Second, MaxDelay: 5 * time.Second}, 4, 5 * time.Second},
+		{Backoff{Delay: time.Second, MaxDelay: 5 * time.Second}, 40, 5 * time.Second},
+		{Backoff{Delay: time.Second}, 4, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if d := tt.backoff.delay(tt.attempt); d != tt.delay {
+			t.Errorf("%+v: delay(%d) = %v, want %v", tt.backoff, tt.attempt, d, tt.delay)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	opts := Options{}
+	opts.Interface.LinkType = layers.LinkTypeEthernet
+	tests := []struct {
+		name     string
+		response *Response
+		snaplen  uint32
+		err      error
+	}{
+		{"older server", nil, MaxSnaplen, nil},
+		{"same link type", &Response{LinkType: uint32(layers.LinkTypeEthernet), Snaplen: 128}, 128, nil},
+		{"link type not reported", &Response{}, MaxSnaplen, nil},
+		{"link type changed", &Response{LinkType: uint32(layers.LinkTypeLinuxSLL)}, 0, ErrLinkTypeChanged},
+	}
+	for _, tt := range tests {
+		snaplen, err := resume(tt.response, opts)
+		if !errors.Is(err, tt.err) || snaplen != tt.snaplen {
+			t.Errorf("%s: resume() = %d, %v, want %d, %v", tt.name, snaplen, err, tt.snaplen, tt.err)
+		}
+	}
+}
+
+//TestReconnectStops check that the captures which can't come back stop at the first attempt, recording their gap
+func TestReconnectStops(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		err   error
+	}{
+		{"container gone", `{"version":1,"status":"error","code":"container_not_found"}`, nil},
+		{"link type changed", `{"version":1,"status":"ok","link_type":113}`, ErrLinkTypeChanged},
+		{"unauthorized", `{"version":1,"status":"error","code":"unauthorized"}`, ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			var attempts int32
+			go func(reply string) {
+				for {
+					c, err := l.Accept()
+					if err != nil {
+						return
+					}
+					atomic.AddInt32(&attempts, 1)
+					bufio.NewReader(c).ReadBytes('\n')
+					c.Write([]byte(reply + "\n"))
+					c.Close()
+				}
+			}(tt.reply)
+
+			opts := Options{Reconnect: Backoff{Delay: time.Millisecond, Attempts: 5}}
+			opts.Interface.LinkType = layers.LinkTypeEthernet
+			gaps := 0
+			opts.OnGap = func(session.Gap) { gaps++ }
+			s := &Stream{Capture: Capture{ContainerName: "web", ContainerNamespace: "default"}}
+			if c, _ := s.reconnect(context.Background(), l.Addr().String(), io.ErrUnexpectedEOF, opts); c != nil {
+				t.Fatal("the capture reconnected")
+			}
+			if n := atomic.LoadInt32(&attempts); n != 1 {
+				t.Errorf("reconnected %d times, want 1", n)
+			}
+			if recorded := s.Gaps(); len(recorded) != 1 || gaps != 1 || recorded[0].Resumed || recorded[0].End.IsZero() {
+				t.Errorf("gaps = %+v, reported %d, want a single gap not resumed", recorded, gaps)
+			}
+			if tt.err != nil && !errors.Is(s.Err(), tt.err) {
+				t.Errorf("Err() = %v, want %v", s.Err(), tt.err)
+			}
+		})
+	}
+}
+
+//TestReconnectResumes check that a capture whose connection dropped appends the packets of the next connection to the same file
+func TestReconnectResumes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// The first connection drops after two frames, the second one sends a frame and stays open
+	frames := [][]int{{0, 1}, {5}}
+	go func() {
+		for _, sent := range frames {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(c)
+			r.ReadBytes('\n')
+			c.Write([]byte(`{"version":1,"status":"ok","link_type":1,"snaplen":128}` + "\n"))
+			for _, second := range sent {
+				c.Write(frame(1600000000+uint32(second), 0, 14, 14, make([]byte, 14)))
+			}
+			if sent[len(sent)-1] == 5 {
+				// Hold the connection until the capture is stopped
+				io.Copy(io.Discard, r)
+			}
+			c.Close()
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "web.pcap")
+	opts := Options{Reconnect: Backoff{Delay: time.Millisecond, Attempts: 3}}
+	opts.File.LinkType = layers.LinkTypeEthernet
+	gaps := make(chan session.Gap, 1)
+	opts.OnGap = func(gap session.Gap) { gaps <- gap }
+	s, err := StartCapture(context.Background(), Capture{ContainerName: "web", ContainerNamespace: "default", FileName: path}, l.Addr().String(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Stats().Packets < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case gap := <-gaps:
+		if !gap.Resumed || gap.Attempts != 1 || !gap.LastPacket.Equal(time.Unix(1600000001, 0)) {
+			t.Errorf("gap = %+v, want a gap resumed at the first attempt after the packet of second 1", gap)
+		}
+	default:
+		t.Error("the gap was not reported")
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seconds := []int64{}
+	for {
+		_, ci, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		seconds = append(seconds, ci.Timestamp.Unix()-1600000000)
+	}
+	if len(seconds) != 3 || seconds[0] != 0 || seconds[1] != 1 || seconds[2] != 5 {
+		t.Errorf("the file holds the packets of seconds %v, want [0 1 5]", seconds)
+	}
+}
@@ -0,0 +1,40 @@
+package socket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+)
+
+//TLSSecretName is the secret created by kpture install, holding the certificates of the proxy and of its clients
+const TLSSecretName = "kpture-tls"
+
+//Keys of the TLS secret
+const (
+	TLSCAKey         = "ca.crt"
+	TLSServerCertKey = "tls.crt"
+	TLSServerKeyKey  = "tls.key"
+	TLSClientCertKey = "client.crt"
+	TLSClientKeyKey  = "client.key"
+)
+
+//ServerName is the name the proxy certificate is issued for, whatever the address it is reached on
+const ServerName = "kpture-proxy"
+
+//ClientTLS return the configuration of the connections to the proxy, verified with the ca certificate.
+//The client certificate and key are optional, they authenticate the client when the proxy asks for it
+func ClientTLS(ca, cert, key []byte) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificate found in the TLS certificate authority")
+	}
+	config := &tls.Config{RootCAs: pool, ServerName: ServerName, MinVersion: tls.VersionTLS12}
+	if len(cert) > 0 || len(key) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
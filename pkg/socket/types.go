@@ -6,4 +6,8 @@ type Capture struct {
 	ContainerID        string `json:"containerID,omitempty"`
 	Interface          string `json:"interface,omitempty"`
 	FileName           string `json:"file_name,omitempty"`
+	//Filter is a BPF expression applied by the capture pod, in tcpdump syntax
+	Filter string `json:"filter,omitempty"`
+	//Snaplen is the snapshot length asked for, zero letting the capture pod choose it
+	Snaplen uint32 `json:"snaplen,omitempty"`
 }